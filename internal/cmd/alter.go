@@ -0,0 +1,183 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// driftWarning is printed before every alter statement: migrations do not
+// know about the change, so schema diff will report it.
+const driftWarning = "warning: this change is not recorded in schema_migrations; schema diff will report drift until a migration makes it"
+
+func newAlterCmd() *cobra.Command {
+	ac := &cobra.Command{
+		Use:   "alter",
+		Short: "Rename tables and columns outside of migrations",
+		Long: `Rename a table or column in place with ALTER TABLE, for experiments. The
+change is not recorded in schema_migrations, so the live schema drifts from
+the one migrations produce; write a migration for anything that should last.
+Every subcommand requires --yes.`,
+		RunE: func(cmd *cobra.Command, args []string) error { return cmd.Help() },
+	}
+
+	var yes bool
+	ac.PersistentFlags().BoolVar(&yes, "yes", false, "Confirm changing the schema outside of migrations")
+
+	ac.AddCommand(&cobra.Command{
+		Use:   "rename-table <old> <new>",
+		Short: "Rename a table",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, to := args[0], args[1]
+			if err := checkAlterName(to); err != nil {
+				return err
+			}
+			if strings.HasPrefix(from, migrationsTable) || strings.HasPrefix(to, migrationsTable) {
+				return fmt.Errorf("refusing to rename the migration bookkeeping tables")
+			}
+
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			if _, err := validateTables(database, []string{from}, false); err != nil {
+				return err
+			}
+			if from == to {
+				return fmt.Errorf("table %s is already named %q", from, to)
+			}
+			stmts := []string{fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quoteIdent(from), quoteIdent(to))}
+			if strings.EqualFold(from, to) {
+				// Only the case changes. The conflict check would find the
+				// table itself, and SQLite refuses the rename for the same
+				// reason, so go through a temporary name.
+				tmp := "_arc_db_rename_" + to
+				if kind, err := schemaObjectKind(database, tmp); err != nil {
+					return err
+				} else if kind != "" {
+					return fmt.Errorf("a %s named %q already exists", kind, tmp)
+				}
+				stmts = []string{
+					fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quoteIdent(from), quoteIdent(tmp)),
+					fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quoteIdent(tmp), quoteIdent(to)),
+				}
+			} else if kind, err := schemaObjectKind(database, to); err != nil {
+				return err
+			} else if kind != "" {
+				return fmt.Errorf("a %s named %q already exists", kind, to)
+			}
+
+			if err := runAlter(cmd, database, yes, stmts...); err != nil {
+				return err
+			}
+			fmt.Printf("Renamed table %s to %s\n", from, to)
+			return nil
+		},
+	})
+
+	ac.AddCommand(&cobra.Command{
+		Use:   "rename-column <table> <old> <new>",
+		Short: "Rename a column of a table",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			table, from, to := args[0], args[1], args[2]
+			if err := checkAlterName(to); err != nil {
+				return err
+			}
+			if strings.HasPrefix(table, migrationsTable) {
+				return fmt.Errorf("refusing to alter the migration bookkeeping tables")
+			}
+
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			if _, err := validateTables(database, []string{table}, false); err != nil {
+				return err
+			}
+			cols, err := tableColumns(database, table)
+			if err != nil {
+				return err
+			}
+			// Column names compare case-insensitively in SQLite.
+			has := func(name string) bool {
+				for _, c := range cols {
+					if strings.EqualFold(c, name) {
+						return true
+					}
+				}
+				return false
+			}
+			if !has(from) {
+				return fmt.Errorf("table %s has no column %q (columns: %s)", table, from, strings.Join(cols, ", "))
+			}
+			if !strings.EqualFold(from, to) && has(to) {
+				return fmt.Errorf("table %s already has a column %q", table, to)
+			}
+
+			stmt := fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", quoteIdent(table), quoteIdent(from), quoteIdent(to))
+			if err := runAlter(cmd, database, yes, stmt); err != nil {
+				return err
+			}
+			fmt.Printf("Renamed column %s.%s to %s\n", table, from, to)
+			return nil
+		},
+	})
+
+	return ac
+}
+
+// checkAlterName applies the rules export uses for table names to a new
+// table or column name, so renamed objects stay exportable.
+func checkAlterName(name string) error {
+	if !safeIdentRe.MatchString(name) {
+		return fmt.Errorf("invalid name %q (use letters, digits, '_' and '-', not starting with a digit)", name)
+	}
+	return nil
+}
+
+// schemaObjectKind returns the type of the sqlite_master object called name,
+// or "" if there is none. Tables, views, indexes and triggers share one
+// namespace, compared case-insensitively.
+func schemaObjectKind(q querier, name string) (string, error) {
+	var kind string
+	err := q.QueryRow(`SELECT type FROM sqlite_master WHERE name = ? COLLATE NOCASE`, name).Scan(&kind)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return kind, err
+}
+
+// runAlter prints stmts with the drift warning and, if yes is set, runs them
+// in one transaction.
+func runAlter(cmd *cobra.Command, database *sql.DB, yes bool, stmts ...string) error {
+	for _, stmt := range stmts {
+		fmt.Println(stmt)
+	}
+	fmt.Fprintln(os.Stderr, driftWarning)
+	if !yes {
+		return fmt.Errorf("refusing to alter the schema without --yes")
+	}
+	tx, err := database.BeginTx(cmd.Context(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range stmts {
+		if _, err := execLogged(cmd.Context(), tx, stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
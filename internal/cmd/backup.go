@@ -0,0 +1,136 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"modernc.org/sqlite"
+)
+
+// backupConn is the part of the modernc driver connection that exposes the
+// SQLite online backup API.
+type backupConn interface {
+	NewBackup(dstURI string) (*sqlite.Backup, error)
+}
+
+var errBackupUnsupported = errors.New("driver does not expose the online backup API")
+
+func newBackupCmd() *cobra.Command {
+	var outPath string
+	var pages int
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Copy the live database using SQLite's online backup API",
+		Long: `Copy the live database page by page into a new file using SQLite's online
+backup API. Unlike VACUUM INTO, writers are only blocked for the duration of
+each step, so the backup can run while the database is in use.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if outPath == "" {
+				return fmt.Errorf("--out is required")
+			}
+			if pages == 0 || pages < -1 {
+				return fmt.Errorf("--pages must be positive or -1 for all at once")
+			}
+			if _, err := os.Stat(outPath); err == nil {
+				if !force {
+					return fmt.Errorf("%s already exists (use --force to overwrite)", outPath)
+				}
+				if err := os.Remove(outPath); err != nil {
+					return err
+				}
+			}
+
+			path := dbPath()
+			database, err := openDB(path)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			var total int
+			if err := database.QueryRow("PRAGMA page_count").Scan(&total); err != nil {
+				return err
+			}
+
+			err = onlineBackup(cmd.Context(), database, outPath, pages, total, func(copied int) {
+				fmt.Printf("\r  %3d%% (%d/%d pages)", backupPercent(copied, total), copied, total)
+			})
+			if errors.Is(err, errBackupUnsupported) {
+				fmt.Println("Online backup API unavailable; falling back to VACUUM INTO")
+				if _, err := database.Exec("VACUUM INTO ?", outPath); err != nil {
+					return err
+				}
+			} else if err != nil {
+				return err
+			} else {
+				fmt.Println()
+			}
+
+			fmt.Printf("Backed up %s to %s\n", path, outPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&outPath, "out", "", "Destination file for the backup")
+	cmd.Flags().IntVar(&pages, "pages", 100, "Pages to copy per step (-1 copies everything in one step)")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite the destination if it exists")
+
+	return cmd
+}
+
+// onlineBackup copies the main database into dst, pages at a time, calling
+// progress with the number of pages copied so far out of total.
+func onlineBackup(ctx context.Context, database *sql.DB, dst string, pages, total int, progress func(int)) error {
+	conn, err := database.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		bc, ok := driverConn.(backupConn)
+		if !ok {
+			return errBackupUnsupported
+		}
+		bk, err := bc.NewBackup(dst)
+		if err != nil {
+			return err
+		}
+
+		copied := 0
+		for {
+			if err := ctx.Err(); err != nil {
+				bk.Finish()
+				return err
+			}
+			more, err := bk.Step(int32(pages))
+			if err != nil {
+				bk.Finish()
+				return err
+			}
+			copied += pages
+			if !more || pages < 0 {
+				progress(total)
+				break
+			}
+			progress(min(copied, total))
+		}
+		return bk.Finish()
+	})
+}
+
+func backupPercent(copied, total int) int {
+	if total <= 0 {
+		return 100
+	}
+	return copied * 100 / total
+}
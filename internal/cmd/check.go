@@ -0,0 +1,120 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newCheckCmd() *cobra.Command {
+	var quick bool
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Run integrity and foreign key checks",
+		Long: `Run PRAGMA integrity_check (or quick_check with --quick) and
+PRAGMA foreign_key_check. Exits non-zero if either reports a problem.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			pragma := "integrity_check"
+			if quick {
+				pragma = "quick_check"
+			}
+			problems, err := integrityProblems(database, pragma)
+			if err != nil {
+				return err
+			}
+			if len(problems) == 0 {
+				fmt.Printf("%s: ok\n", pragma)
+			} else {
+				fmt.Printf("%s: %d problem(s)\n", pragma, len(problems))
+				for _, p := range problems {
+					fmt.Printf("  %s\n", p)
+				}
+			}
+
+			violations, err := foreignKeyViolations(database)
+			if err != nil {
+				return err
+			}
+			if len(violations) == 0 {
+				fmt.Println("foreign_key_check: ok")
+			} else {
+				fmt.Printf("foreign_key_check: %d violation(s)\n", len(violations))
+				for _, v := range violations {
+					fmt.Printf("  %s rowid %s -> %s (constraint %d)\n", v.table, v.rowid, v.parent, v.fkid)
+				}
+			}
+
+			if len(problems) > 0 || len(violations) > 0 {
+				return fmt.Errorf("database check failed")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&quick, "quick", false, "Use PRAGMA quick_check instead of the full integrity_check")
+
+	return cmd
+}
+
+// integrityProblems runs pragma (integrity_check or quick_check) and returns
+// every message other than "ok".
+func integrityProblems(database *sql.DB, pragma string) ([]string, error) {
+	rows, err := database.Query("PRAGMA " + pragma)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return nil, err
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	return problems, rows.Err()
+}
+
+type fkViolation struct {
+	table  string
+	rowid  string
+	parent string
+	fkid   int
+}
+
+func foreignKeyViolations(database *sql.DB) ([]fkViolation, error) {
+	rows, err := database.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []fkViolation
+	for rows.Next() {
+		var v fkViolation
+		var rowid sql.NullString
+		if err := rows.Scan(&v.table, &rowid, &v.parent, &v.fkid); err != nil {
+			return nil, err
+		}
+		v.rowid = rowid.String
+		if !rowid.Valid {
+			v.rowid = "NULL"
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// volatileTables are emptied in a clone by clone --reset.
+var volatileTables = []string{"sessions"}
+
+func newCloneCmd() *cobra.Command {
+	var outPath string
+	var force bool
+	var reset bool
+
+	cmd := &cobra.Command{
+		Use:   "clone",
+		Short: "Copy the database to a new working database file",
+		Long: `Checkpoint the write-ahead log, then write a consistent, compacted copy of
+the database to --out with VACUUM INTO. Unlike backup, the result is meant to
+be opened by another process, e.g. as an isolated test environment.
+
+With --reset, volatile tables (sessions) are emptied in the clone; the source
+database is never modified.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if outPath == "" {
+				return fmt.Errorf("--out is required")
+			}
+			if _, err := os.Stat(outPath); err == nil {
+				if !force {
+					return fmt.Errorf("%s already exists (use --force to overwrite)", outPath)
+				}
+				for _, p := range []string{outPath, outPath + "-wal", outPath + "-shm"} {
+					if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+						return err
+					}
+				}
+			}
+
+			path := dbPath()
+			database, err := openDB(path)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			res, err := walCheckpoint(cmd.Context(), database, "TRUNCATE")
+			if err != nil {
+				return fmt.Errorf("checkpoint: %w", err)
+			}
+			if res.busy {
+				fmt.Fprintln(os.Stderr, "warning: checkpoint did not complete; the clone is still consistent")
+			}
+			if _, err := execLogged(cmd.Context(), database, "VACUUM INTO ?", outPath); err != nil {
+				return err
+			}
+
+			if reset {
+				if err := resetClone(cmd, outPath); err != nil {
+					return fmt.Errorf("reset %s: %w", outPath, err)
+				}
+			}
+
+			st, err := os.Stat(outPath)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Cloned %s to %s (%d bytes)\n", path, outPath, st.Size())
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&outPath, "out", "", "Destination file for the clone")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite the destination if it exists")
+	cmd.Flags().BoolVar(&reset, "reset", false, "Empty volatile tables (sessions) in the clone")
+
+	return cmd
+}
+
+// resetClone deletes every row from the volatile tables present in the
+// clone at path, in one transaction.
+func resetClone(cmd *cobra.Command, path string) error {
+	database, err := openDB(path)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	tx, err := database.BeginTx(cmd.Context(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range volatileTables {
+		if ok, err := checkTable(tx, table); err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+		res, err := execLogged(cmd.Context(), tx, "DELETE FROM "+quoteIdent(table))
+		if err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+		n, _ := res.RowsAffected()
+		fmt.Printf("Reset %s (%d rows deleted)\n", table, n)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newCountCmd() *cobra.Command {
+	var where string
+
+	cmd := &cobra.Command{
+		Use:   "count <table>",
+		Short: "Print the number of rows in a table",
+		Long: `Print a table's row count, optionally filtered by --where, as a bare number
+for use in scripts. Exits non-zero if the table does not exist.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			table := args[0]
+
+			database, err := openReadOnly(dbPath(), false)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			if ok, err := checkTable(database, table); err != nil {
+				return err
+			} else if !ok {
+				return unknownTableError(database, table)
+			}
+
+			from := quoteIdent(table)
+			if w := strings.TrimSpace(where); w != "" {
+				from += " WHERE " + w
+			}
+			n, err := countRows(ctxQuerier{ctx: cmd.Context(), q: database}, from)
+			if err != nil {
+				return err
+			}
+			fmt.Println(n)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&where, "where", "", "Only count rows matching this SQL predicate")
+
+	return cmd
+}
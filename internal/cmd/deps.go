@@ -0,0 +1,212 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// depEdge is one row of repo_dependencies: From depends on To.
+type depEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+var (
+	depRepoColumns = []string{"repo_name", "repo"}
+	depDepColumns  = []string{"dependency_name", "depends_on", "dependency"}
+)
+
+func newDepsCmd() *cobra.Command {
+	dc := &cobra.Command{
+		Use:   "deps",
+		Short: "Inspect repo dependencies",
+		RunE:  func(cmd *cobra.Command, args []string) error { return cmd.Help() },
+	}
+
+	var format string
+	graphCmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Print the dependency graph as Graphviz DOT or JSON",
+		Long: `Print the edges in repo_dependencies as a graph, one node per repo or
+dependency. Pipe the DOT output into Graphviz, e.g.:
+
+  arc-db deps graph | dot -Tpng -o deps.png`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "dot" && format != "json" {
+				return fmt.Errorf("unsupported format %q (want dot or json)", format)
+			}
+
+			database, err := openReadOnly(dbPath(), false)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			edges, err := depEdges(database)
+			if err != nil {
+				return err
+			}
+
+			if format == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(map[string]any{"nodes": depNodes(edges), "edges": edges})
+			}
+			fmt.Println("digraph deps {")
+			for _, n := range depNodes(edges) {
+				fmt.Printf("  %s;\n", strconv.Quote(n))
+			}
+			for _, e := range edges {
+				fmt.Printf("  %s -> %s;\n", strconv.Quote(e.From), strconv.Quote(e.To))
+			}
+			fmt.Println("}")
+			return nil
+		},
+	}
+	graphCmd.Flags().StringVar(&format, "format", "dot", "Output format: dot or json")
+	dc.AddCommand(graphCmd)
+
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Fail if repo_dependencies contains cycles",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := openReadOnly(dbPath(), false)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			edges, err := depEdges(database)
+			if err != nil {
+				return err
+			}
+
+			selfLoops, cycles := depCycles(edges)
+			for _, n := range selfLoops {
+				fmt.Printf("self-dependency: %s -> %s\n", n, n)
+			}
+			for _, c := range cycles {
+				fmt.Printf("cycle: %s\n", strings.Join(c, " -> "))
+			}
+			if len(selfLoops) == 0 && len(cycles) == 0 {
+				fmt.Printf("No cycles in %d dependency edges.\n", len(edges))
+				return nil
+			}
+			return fmt.Errorf("found %d cycle(s) and %d self-dependency(ies)", len(cycles), len(selfLoops))
+		},
+	}
+	dc.AddCommand(checkCmd)
+
+	return dc
+}
+
+// depEdges reads the distinct edges of repo_dependencies, failing with the
+// columns it found when the table doesn't have a repo/dependency pair.
+func depEdges(q querier) ([]depEdge, error) {
+	cols, err := tableColumns(q, "repo_dependencies")
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("no repo_dependencies table")
+	}
+	from, to := firstColumn(cols, depRepoColumns), firstColumn(cols, depDepColumns)
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("repo_dependencies needs a repo column (%s) and a dependency column (%s); found: %s",
+			strings.Join(depRepoColumns, " or "), strings.Join(depDepColumns, " or "), strings.Join(cols, ", "))
+	}
+
+	rows, err := q.Query(fmt.Sprintf("SELECT DISTINCT %s, %s FROM repo_dependencies WHERE %s IS NOT NULL AND %s IS NOT NULL ORDER BY 1, 2",
+		quoteIdent(from), quoteIdent(to), quoteIdent(from), quoteIdent(to)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []depEdge
+	for rows.Next() {
+		var e depEdge
+		if err := rows.Scan(&e.From, &e.To); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// depCycles finds self-loops and, by depth-first search with white/grey/black
+// colouring, one cycle per back edge. Each cycle is returned as a path that
+// starts and ends with the same node.
+func depCycles(edges []depEdge) (selfLoops []string, cycles [][]string) {
+	adj := map[string][]string{}
+	for _, e := range edges {
+		if e.From == e.To {
+			selfLoops = append(selfLoops, e.From)
+			continue
+		}
+		adj[e.From] = append(adj[e.From], e.To)
+	}
+	for _, next := range adj {
+		sort.Strings(next)
+	}
+
+	const (
+		white = iota
+		grey
+		black
+	)
+	color := map[string]int{}
+	var stack []string
+	var visit func(n string)
+	visit = func(n string) {
+		color[n] = grey
+		stack = append(stack, n)
+		for _, m := range adj[n] {
+			switch color[m] {
+			case white:
+				visit(m)
+			case grey:
+				// Back edge: the cycle is the stack from m to n, closed by m.
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == m {
+						cycle := append(append([]string{}, stack[i:]...), m)
+						cycles = append(cycles, cycle)
+						break
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		color[n] = black
+	}
+	for _, n := range depNodes(edges) {
+		if color[n] == white {
+			visit(n)
+		}
+	}
+	return selfLoops, cycles
+}
+
+func depNodes(edges []depEdge) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, e := range edges {
+		for _, n := range []string{e.From, e.To} {
+			if !seen[n] {
+				seen[n] = true
+				out = append(out, n)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,298 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newDiffCmd() *cobra.Command {
+	var pathA, pathB string
+	var tablesCSV string
+	var showRows bool
+
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare the data in two database files",
+		Long: `Compare row counts between two database files, attached to a single
+connection. With --rows, list added, removed and changed rows keyed by primary
+key; tables without a primary key are compared on full rows and only the
+number of differing rows is reported.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if pathA == "" || pathB == "" {
+				return fmt.Errorf("--a and --b are required")
+			}
+
+			conn, err := openDiff(pathA, pathB)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			tables := parseTableList(tablesCSV)
+			if len(tables) == 0 {
+				if tables, err = diffTables(conn); err != nil {
+					return err
+				}
+			}
+
+			for _, tbl := range tables {
+				if err := diffTable(conn, tbl, showRows); err != nil {
+					return fmt.Errorf("diff %s: %w", tbl, err)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&pathA, "a", "", "Original database file")
+	cmd.Flags().StringVar(&pathB, "b", "", "Database file to compare against --a")
+	cmd.Flags().StringVar(&tablesCSV, "table", "", "Comma-separated tables to compare (default: all)")
+	cmd.Flags().BoolVar(&showRows, "rows", false, "List row-level differences")
+
+	return cmd
+}
+
+// openDiff opens pathA read-only as main and attaches pathB as b. The pool
+// is limited to one connection so the attachment is visible to every query.
+func openDiff(pathA, pathB string) (*sql.DB, error) {
+	for _, p := range []string{pathA, pathB} {
+		if err := validateSQLiteHeader(p); err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	conn, err := sql.Open("sqlite", readOnlyDSN(pathA, false))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetMaxOpenConns(1)
+	if _, err := conn.Exec("ATTACH DATABASE ? AS b", readOnlyDSN(pathB, false)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("attach %s: %w", pathB, err)
+	}
+	return conn, nil
+}
+
+// diffTables returns the user tables present in either database.
+func diffTables(q querier) ([]string, error) {
+	rows, err := q.Query(`SELECT name FROM main.sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%'
+		UNION SELECT name FROM b.sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%'
+		ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		out = append(out, name)
+	}
+	return out, rows.Err()
+}
+
+// schemaColumns lists the columns of table in the given attached schema and
+// the primary key columns in key order.
+func schemaColumns(q querier, schema, table string) (cols, pk []string, err error) {
+	rows, err := q.Query(`SELECT name, pk FROM pragma_table_info(?, ?) ORDER BY cid`, table, schema)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	keyed := map[int]string{}
+	for rows.Next() {
+		var name string
+		var pos int
+		if err := rows.Scan(&name, &pos); err != nil {
+			return nil, nil, err
+		}
+		cols = append(cols, name)
+		if pos > 0 {
+			keyed[pos] = name
+		}
+	}
+	for i := 1; i <= len(keyed); i++ {
+		pk = append(pk, keyed[i])
+	}
+	return cols, pk, rows.Err()
+}
+
+func diffTable(q querier, table string, showRows bool) error {
+	colsA, pkA, err := schemaColumns(q, "main", table)
+	if err != nil {
+		return err
+	}
+	colsB, pkB, err := schemaColumns(q, "b", table)
+	if err != nil {
+		return err
+	}
+
+	ident := quoteIdent(table)
+	switch {
+	case len(colsA) == 0 && len(colsB) == 0:
+		return fmt.Errorf("no such table")
+	case len(colsB) == 0:
+		n, err := countRows(q, "main."+ident)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: only in a (%d rows)\n", table, n)
+		return nil
+	case len(colsA) == 0:
+		n, err := countRows(q, "b."+ident)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: only in b (%d rows)\n", table, n)
+		return nil
+	}
+
+	na, err := countRows(q, "main."+ident)
+	if err != nil {
+		return err
+	}
+	nb, err := countRows(q, "b."+ident)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d -> %d rows\n", table, na, nb)
+	if !showRows {
+		return nil
+	}
+
+	var common []string
+	for _, c := range colsA {
+		if containsString(colsB, c) {
+			common = append(common, c)
+		}
+	}
+	if len(common) < len(colsA) || len(common) < len(colsB) {
+		fmt.Printf("  columns differ; comparing %s\n", strings.Join(common, ", "))
+	}
+
+	if len(pkA) == 0 || strings.Join(pkA, ",") != strings.Join(pkB, ",") {
+		return diffFullRows(q, ident, common)
+	}
+	return diffKeyedRows(q, ident, pkA, common)
+}
+
+func countRows(q querier, qualified string) (int, error) {
+	var n int
+	err := q.QueryRow("SELECT count(*) FROM " + qualified).Scan(&n)
+	return n, err
+}
+
+// diffFullRows compares whole rows for tables without a usable primary key
+// and reports only how many rows appear on one side but not the other.
+func diffFullRows(q querier, ident string, cols []string) error {
+	sel := quoteList(cols)
+	var added, removed int
+	if err := q.QueryRow(fmt.Sprintf("SELECT count(*) FROM (SELECT %s FROM b.%s EXCEPT SELECT %s FROM main.%s)", sel, ident, sel, ident)).Scan(&added); err != nil {
+		return err
+	}
+	if err := q.QueryRow(fmt.Sprintf("SELECT count(*) FROM (SELECT %s FROM main.%s EXCEPT SELECT %s FROM b.%s)", sel, ident, sel, ident)).Scan(&removed); err != nil {
+		return err
+	}
+	fmt.Printf("  no primary key; %d added, %d removed (full-row comparison)\n", added, removed)
+	return nil
+}
+
+func diffKeyedRows(q querier, ident string, pk, cols []string) error {
+	var join []string
+	for _, k := range pk {
+		join = append(join, fmt.Sprintf("x.%s = y.%s", quoteIdent(k), quoteIdent(k)))
+	}
+	on := strings.Join(join, " AND ")
+	keySel := "y." + strings.Join(quoteEach(pk), ", y.")
+
+	printKeys := func(mark, stmt string) error {
+		rows, err := q.Query(stmt)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			vals, err := scanRow(rows, len(pk))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("  %s %s\n", mark, formatKey(pk, vals))
+		}
+		return rows.Err()
+	}
+
+	if err := printKeys("+", fmt.Sprintf("SELECT %s FROM b.%s y WHERE NOT EXISTS (SELECT 1 FROM main.%s x WHERE %s) ORDER BY %s",
+		keySel, ident, ident, on, keySel)); err != nil {
+		return err
+	}
+	if err := printKeys("-", fmt.Sprintf("SELECT %s FROM main.%s y WHERE NOT EXISTS (SELECT 1 FROM b.%s x WHERE %s) ORDER BY %s",
+		keySel, ident, ident, on, keySel)); err != nil {
+		return err
+	}
+
+	var values []string
+	for _, c := range cols {
+		if !containsString(pk, c) {
+			values = append(values, c)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	var sel, differ []string
+	for _, c := range values {
+		qc := quoteIdent(c)
+		sel = append(sel, "x."+qc, "y."+qc)
+		differ = append(differ, fmt.Sprintf("x.%s IS NOT y.%s", qc, qc))
+	}
+	rows, err := q.Query(fmt.Sprintf("SELECT %s, %s FROM main.%s x JOIN b.%s y ON %s WHERE %s ORDER BY %s",
+		keySel, strings.Join(sel, ", "), ident, ident, on, strings.Join(differ, " OR "), keySel))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		vals, err := scanRow(rows, len(pk)+2*len(values))
+		if err != nil {
+			return err
+		}
+		var changes []string
+		for i, c := range values {
+			before, after := vals[len(pk)+2*i], vals[len(pk)+2*i+1]
+			if fmt.Sprintf("%#v", before) != fmt.Sprintf("%#v", after) {
+				changes = append(changes, fmt.Sprintf("%s: %s -> %s", c, displayValue(before), displayValue(after)))
+			}
+		}
+		fmt.Printf("  ~ %s (%s)\n", formatKey(pk, vals[:len(pk)]), strings.Join(changes, ", "))
+	}
+	return rows.Err()
+}
+
+func formatKey(pk []string, vals []any) string {
+	parts := make([]string, len(pk))
+	for i, k := range pk {
+		parts[i] = k + "=" + displayValue(vals[i])
+	}
+	return strings.Join(parts, " ")
+}
+
+func quoteEach(names []string) []string {
+	out := make([]string, len(names))
+	for i, n := range names {
+		out[i] = quoteIdent(n)
+	}
+	return out
+}
+
+func quoteList(names []string) string {
+	return strings.Join(quoteEach(names), ", ")
+}
@@ -0,0 +1,271 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Thresholds above which doctor recommends maintenance.
+const (
+	freePageWarnRatio = 0.25
+	walWarnBytes      = 64 << 20
+	// statsStaleFactor is how far a table's row count may drift from the
+	// count ANALYZE recorded before its statistics count as stale.
+	statsStaleFactor = 2
+	statsStaleMinRow = 1000
+)
+
+type severity int
+
+const (
+	severityWarning severity = iota
+	severityCritical
+)
+
+func (s severity) String() string {
+	if s == severityCritical {
+		return "critical"
+	}
+	return "warning"
+}
+
+// finding is one problem reported by a health check, with the action that
+// fixes it.
+type finding struct {
+	severity severity
+	action   string
+	reason   string
+}
+
+// healthCheck is a read-only check that doctor runs. Checks only query the
+// database, so other commands can run them too.
+type healthCheck struct {
+	name string
+	run  func(database *sql.DB, path string) ([]finding, error)
+}
+
+var healthChecks = []healthCheck{
+	{"integrity", checkIntegrity},
+	{"foreign_keys", checkForeignKeys},
+	{"free_pages", checkFreePages},
+	{"stats", checkStats},
+	{"wal", checkWALSize},
+}
+
+func newDoctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Run health checks and recommend maintenance",
+		Long: `Run read-only health checks (integrity, foreign keys, free pages, planner
+statistics and WAL size) and print the recommended actions, most urgent first.
+Exits non-zero if any critical issue is found.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := dbPath()
+			database, err := openReadOnly(path, false)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			var all []finding
+			for _, c := range healthChecks {
+				found, err := c.run(database, path)
+				if err != nil {
+					return fmt.Errorf("%s: %w", c.name, err)
+				}
+				if len(found) == 0 {
+					fmt.Printf("%-14s ok\n", c.name+":")
+				} else {
+					fmt.Printf("%-14s %d issue(s)\n", c.name+":", len(found))
+				}
+				all = append(all, found...)
+			}
+
+			if len(all) == 0 {
+				fmt.Println("\nNo issues found.")
+				return nil
+			}
+			sort.SliceStable(all, func(i, j int) bool { return all[i].severity > all[j].severity })
+			fmt.Println("\nRecommended actions:")
+			critical := 0
+			for i, f := range all {
+				if f.severity == severityCritical {
+					critical++
+				}
+				fmt.Printf("  %d. [%s] %s: %s\n", i+1, f.severity, f.action, f.reason)
+			}
+			if critical > 0 {
+				return fmt.Errorf("%d critical issue(s) found", critical)
+			}
+			return nil
+		},
+	}
+}
+
+func checkIntegrity(database *sql.DB, path string) ([]finding, error) {
+	problems, err := integrityProblems(database, "integrity_check")
+	if err != nil || len(problems) == 0 {
+		return nil, err
+	}
+	return []finding{{
+		severity: severityCritical,
+		action:   "restore from a backup",
+		reason:   fmt.Sprintf("integrity_check reported %d problem(s); run arc-db check for details", len(problems)),
+	}}, nil
+}
+
+func checkForeignKeys(database *sql.DB, path string) ([]finding, error) {
+	violations, err := foreignKeyViolations(database)
+	if err != nil || len(violations) == 0 {
+		return nil, err
+	}
+	tables := map[string]bool{}
+	var names []string
+	for _, v := range violations {
+		if !tables[v.table] {
+			tables[v.table] = true
+			names = append(names, v.table)
+		}
+	}
+	sort.Strings(names)
+	return []finding{{
+		severity: severityCritical,
+		action:   "fix orphaned rows",
+		reason:   fmt.Sprintf("%d foreign key violation(s) in %s; run arc-db check to list them", len(violations), strings.Join(names, ", ")),
+	}}, nil
+}
+
+func checkFreePages(database *sql.DB, path string) ([]finding, error) {
+	var pageSize, pages, free int64
+	for _, p := range []struct {
+		pragma string
+		dst    *int64
+	}{
+		{"page_size", &pageSize},
+		{"page_count", &pages},
+		{"freelist_count", &free},
+	} {
+		if err := database.QueryRow("PRAGMA " + p.pragma).Scan(p.dst); err != nil {
+			return nil, fmt.Errorf("%s: %w", p.pragma, err)
+		}
+	}
+	if pages == 0 {
+		return nil, nil
+	}
+	ratio := float64(free) / float64(pages)
+	if ratio < freePageWarnRatio {
+		return nil, nil
+	}
+	return []finding{{
+		severity: severityWarning,
+		action:   "run vacuum",
+		reason:   fmt.Sprintf("%.0f%% free pages (%d bytes reclaimable)", ratio*100, free*pageSize),
+	}}, nil
+}
+
+// checkStats compares the row counts ANALYZE stored in sqlite_stat1 with
+// the current ones. A database with indexes but no statistics at all also
+// gets a recommendation.
+func checkStats(database *sql.DB, path string) ([]finding, error) {
+	var indexes int
+	if err := database.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'index'`).Scan(&indexes); err != nil {
+		return nil, err
+	}
+	if indexes == 0 {
+		return nil, nil
+	}
+	ok, err := checkTable(database, "sqlite_stat1")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return []finding{{
+			severity: severityWarning,
+			action:   "run analyze",
+			reason:   "no query planner statistics have been collected",
+		}}, nil
+	}
+
+	recorded, err := recordedRowCounts(database)
+	if err != nil {
+		return nil, err
+	}
+	var stale []string
+	tables := make([]string, 0, len(recorded))
+	for t := range recorded {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+	for _, t := range tables {
+		if ok, err := tableExists(database, t); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		now, err := countRows(database, quoteIdent(t))
+		if err != nil {
+			return nil, fmt.Errorf("count %s: %w", t, err)
+		}
+		then := recorded[t]
+		lo, hi := min(then, now), max(then, now)
+		if hi-lo >= statsStaleMinRow && hi >= lo*statsStaleFactor {
+			stale = append(stale, fmt.Sprintf("%s (%d recorded, %d now)", t, then, now))
+		}
+	}
+	if len(stale) == 0 {
+		return nil, nil
+	}
+	return []finding{{
+		severity: severityWarning,
+		action:   "run analyze",
+		reason:   "statistics are stale for " + strings.Join(stale, ", "),
+	}}, nil
+}
+
+// recordedRowCounts reads the per-table row estimate, the first field of
+// sqlite_stat1.stat, keeping the largest seen for each table.
+func recordedRowCounts(database *sql.DB) (map[string]int, error) {
+	rows, err := database.Query(`SELECT tbl, stat FROM sqlite_stat1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]int{}
+	for rows.Next() {
+		var tbl string
+		var stat sql.NullString
+		if err := rows.Scan(&tbl, &stat); err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(stat.String)
+		if len(fields) == 0 {
+			continue
+		}
+		if n, err := strconv.Atoi(fields[0]); err == nil && n > out[tbl] {
+			out[tbl] = n
+		}
+	}
+	return out, rows.Err()
+}
+
+func checkWALSize(database *sql.DB, path string) ([]finding, error) {
+	st, err := os.Stat(path + "-wal")
+	if err != nil || st.Size() < walWarnBytes {
+		return nil, nil
+	}
+	return []finding{{
+		severity: severityWarning,
+		action:   "run checkpoint",
+		reason:   fmt.Sprintf("WAL is %d bytes", st.Size()),
+	}}, nil
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const envBackupsTable = "env_backups"
+
+// The env_backups schema comes from the SDK migrations, so the columns
+// holding the backup and its timestamp are looked up by name rather than
+// assumed. The first candidate present wins.
+var (
+	envBackupContentColumns = []string{"content", "data", "body", "env"}
+	envBackupTimeColumns    = []string{"created_at", "mtime", "ts", "updated_at"}
+)
+
+// envBackupColumns is the resolved layout of env_backups. id is "rowid"
+// unless the table declares an id column; content and ts are empty when no
+// candidate column exists.
+type envBackupColumns struct {
+	id, content, ts string
+	all             []string
+}
+
+func loadEnvBackupColumns(q querier) (envBackupColumns, error) {
+	var c envBackupColumns
+	if ok, err := checkTable(q, envBackupsTable); err != nil {
+		return c, err
+	} else if !ok {
+		return c, fmt.Errorf("no %s table (run arc-db migrate up)", envBackupsTable)
+	}
+	cols, err := tableColumns(q, envBackupsTable)
+	if err != nil {
+		return c, err
+	}
+	c.all = cols
+	c.id = "rowid"
+	if firstColumn(cols, []string{"id"}) != "" {
+		c.id = "id"
+	}
+	c.content = firstColumn(cols, envBackupContentColumns)
+	c.ts = firstColumn(cols, envBackupTimeColumns)
+	return c, nil
+}
+
+func newEnvBackupsCmd() *cobra.Command {
+	ec := &cobra.Command{
+		Use:   "env-backups",
+		Short: "List and restore stored environment backups",
+		Long: `List the environment backups stored in env_backups and write one back to a
+file. The columns holding the backup and its timestamp are found by name
+(content, data, body or env; created_at, mtime, ts or updated_at). If the
+table has no content column it only records backup metadata, and restore
+fails saying so.`,
+		RunE: func(cmd *cobra.Command, args []string) error { return cmd.Help() },
+	}
+
+	ec.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List stored environment backups",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := openReadOnly(dbPath(), false)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			c, err := loadEnvBackupColumns(database)
+			if err != nil {
+				return err
+			}
+			ts, size, order := "NULL", "NULL", c.id
+			if c.ts != "" {
+				ts = quoteIdent(c.ts)
+				order = ts + ", " + c.id
+			}
+			if c.content != "" {
+				size = "length(" + quoteIdent(c.content) + ")"
+			}
+			rows, err := database.Query(fmt.Sprintf(`SELECT %s, %s, %s FROM %s ORDER BY %s`,
+				c.id, ts, size, quoteIdent(envBackupsTable), order))
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			n := 0
+			for rows.Next() {
+				var id, when sql.NullString
+				var length sql.NullInt64
+				if err := rows.Scan(&id, &when, &length); err != nil {
+					return err
+				}
+				if n == 0 {
+					fmt.Printf("%-24s %-24s %s\n", "ID", "CREATED", "BYTES")
+				}
+				n++
+				size := "-"
+				if length.Valid {
+					size = strconv.FormatInt(length.Int64, 10)
+				}
+				fmt.Printf("%-24s %-24s %s\n", id.String, formatEnvBackupTime(when), size)
+			}
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			if n == 0 {
+				fmt.Println("No environment backups.")
+			} else if c.content == "" {
+				fmt.Fprintf(os.Stderr, "warning: %s has no content column (columns: %s); these backups cannot be restored\n",
+					envBackupsTable, strings.Join(c.all, ", "))
+			}
+			return nil
+		},
+	})
+
+	var outPath string
+	var force bool
+	restoreCmd := &cobra.Command{
+		Use:   "restore <id>",
+		Short: "Write a stored environment backup to a file",
+		Long: `Write the backup with the given id to --out. Gzip-compressed backups are
+decompressed. The file is created with mode 0600, since backups usually
+hold secrets, and an existing file is only replaced with --force.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := args[0]
+			if _, err := os.Stat(outPath); err == nil && !force {
+				return fmt.Errorf("%s already exists (use --force to overwrite)", outPath)
+			}
+
+			database, err := openReadOnly(dbPath(), false)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			c, err := loadEnvBackupColumns(database)
+			if err != nil {
+				return err
+			}
+			if c.content == "" {
+				return fmt.Errorf("%s has no content column (columns: %s); it records backup metadata only, so there is nothing to restore",
+					envBackupsTable, strings.Join(c.all, ", "))
+			}
+
+			var raw any
+			err = database.QueryRow(fmt.Sprintf(`SELECT %s FROM %s WHERE %s = ?`,
+				quoteIdent(c.content), quoteIdent(envBackupsTable), c.id), envBackupID(id)).Scan(&raw)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("no environment backup with id %q (see arc-db env-backups list)", id)
+			}
+			if err != nil {
+				return err
+			}
+			content, err := decodeEnvBackup(raw)
+			if err != nil {
+				return fmt.Errorf("backup %s: %w", id, err)
+			}
+			if err := writeFileAtomic(outPath, content, 0o600); err != nil {
+				return err
+			}
+			fmt.Printf("Restored environment backup %s to %s (%d bytes)\n", id, outPath, len(content))
+			return nil
+		},
+	}
+	restoreCmd.Flags().StringVar(&outPath, "out", ".env", "File to write the backup to")
+	restoreCmd.Flags().BoolVar(&force, "force", false, "Overwrite --out if it exists")
+	ec.AddCommand(restoreCmd)
+
+	return ec
+}
+
+// envBackupID lets integer ids match INTEGER columns and rowid exactly.
+func envBackupID(id string) any {
+	if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+		return n
+	}
+	return id
+}
+
+// decodeEnvBackup returns the bytes of a stored backup, gunzipping values
+// that start with the gzip magic number.
+func decodeEnvBackup(v any) ([]byte, error) {
+	var b []byte
+	switch v := v.(type) {
+	case nil:
+		return nil, fmt.Errorf("content is NULL")
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return nil, fmt.Errorf("content is a %T, not text or a blob", v)
+	}
+	if !bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		return b, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("decompress: %w", err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("decompress: %w", err)
+	}
+	return out, nil
+}
+
+// formatEnvBackupTime shows Unix timestamps as dates and anything else as
+// stored.
+func formatEnvBackupTime(s sql.NullString) string {
+	if !s.Valid {
+		return "-"
+	}
+	if n, err := strconv.ParseInt(s.String, 10, 64); err == nil {
+		return time.Unix(n, 0).Format(time.RFC3339)
+	}
+	return s.String
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so a failed write never leaves a truncated file. Creating
+// the temporary file also checks that the directory is writable.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,1378 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// sqlInsertBatch is the number of rows per INSERT statement in SQL dumps.
+const sqlInsertBatch = 100
+
+// safeIdentRe matches the table names accepted on the command line. Names
+// read back from sqlite_master are not checked against it; every name is
+// quoted when interpolated into SQL.
+var safeIdentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// checkTable rejects unsafe user-supplied table names and reports whether
+// table exists in sqlite_master.
+func checkTable(database querier, table string) (bool, error) {
+	if !safeIdentRe.MatchString(table) {
+		return false, fmt.Errorf("invalid table name %q", table)
+	}
+	return tableExists(database, table)
+}
+
+// tableExists reports whether table exists in sqlite_master, whatever its
+// name. Use it for names that came from the database itself.
+func tableExists(database querier, table string) (bool, error) {
+	var cnt int
+	if err := database.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type='table' AND name=?`, table).Scan(&cnt); err != nil {
+		return false, err
+	}
+	return cnt > 0, nil
+}
+
+// validateTables checks each requested table against sqlite_master. A missing
+// table is an error naming the closest existing table, unless ignoreMissing
+// is set, in which case it is silently dropped from the result.
+func validateTables(q querier, requested []string, ignoreMissing bool) ([]string, error) {
+	out := make([]string, 0, len(requested))
+	for _, t := range requested {
+		ok, err := checkTable(q, t)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, t)
+			continue
+		}
+		if !ignoreMissing {
+			return nil, unknownTableError(q, t)
+		}
+	}
+	return out, nil
+}
+
+// exportableTables lists the tables export covers by default: every user
+// table except schema_migrations and schema_migrations_lock, which describe
+// the database rather than hold its data. Tables that only share their
+// prefix are exported. includeMigrations adds schema_migrations back; the
+// migration lock is never exported.
+func exportableTables(q querier, includeMigrations bool) ([]string, error) {
+	tables, err := listTables(q)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(tables))
+	for _, t := range tables {
+		if t == migrationLockTable || (t == migrationsTable && !includeMigrations) {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// excludeTables returns tables without the names in exclude, keeping order.
+func excludeTables(tables, exclude []string) []string {
+	out := make([]string, 0, len(tables))
+	for _, t := range tables {
+		if !containsString(exclude, t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// unknownTableError reports that table does not exist, suggesting the
+// existing table with the smallest edit distance when it is a likely typo.
+func unknownTableError(q querier, table string) error {
+	tables, err := listTables(q)
+	if err != nil {
+		return err
+	}
+	best, bestDist := "", -1
+	for _, t := range tables {
+		if d := editDistance(strings.ToLower(table), strings.ToLower(t)); bestDist < 0 || d < bestDist {
+			best, bestDist = t, d
+		}
+	}
+	if bestDist >= 0 && bestDist <= max(2, len(table)/4) {
+		return fmt.Errorf("unknown table %q (did you mean %q?)", table, best)
+	}
+	return fmt.Errorf("unknown table %q", table)
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// listTables returns the user tables in the database, sorted by name.
+func listTables(database querier) ([]string, error) {
+	rows, err := database.Query(`SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// ctxQuerier adapts a *sql.DB or *sql.Tx to querier, running every query
+// under ctx so cancellation interrupts it.
+type ctxQuerier struct {
+	ctx context.Context
+	q   interface {
+		QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+		QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	}
+}
+
+// Query and QueryRow are traced up to the first row; time spent reading
+// the rest of the result is not included in the span.
+func (c ctxQuerier) Query(query string, args ...any) (*sql.Rows, error) {
+	_, end := startSpan(c.ctx, "sql", summarizeStatement(query))
+	rows, err := c.q.QueryContext(c.ctx, query, args...)
+	end(err)
+	return rows, err
+}
+
+func (c ctxQuerier) QueryRow(query string, args ...any) *sql.Row {
+	_, end := startSpan(c.ctx, "sql", summarizeStatement(query))
+	row := c.q.QueryRowContext(c.ctx, query, args...)
+	end(row.Err())
+	return row
+}
+
+// exportFormat writes tables in one output format. begin and end frame each
+// output stream; table writes one table, calling onRow after every row, and
+// returns its row count.
+type exportFormat struct {
+	ext   string
+	begin func(w io.Writer, tables []string) error
+	table func(q querier, w io.Writer, table string, onRow func() error) (int, error)
+	end   func(w io.Writer) error
+}
+
+// Export compression algorithms for --compress.
+const (
+	compressNone = "none"
+	compressGzip = "gzip"
+	compressZstd = "zstd"
+)
+
+var errZstdUnsupported = errors.New("this arc-db was built without zstd support; rebuild with -tags zstd")
+
+// compressExt is the file extension appended for a compression algorithm.
+func compressExt(compress string) string {
+	switch compress {
+	case compressGzip:
+		return ".gz"
+	case compressZstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// compressContentTypes maps compression algorithms to the Content-Type of a
+// compressed object upload.
+var compressContentTypes = map[string]string{
+	compressGzip: "application/gzip",
+	compressZstd: "application/zstd",
+}
+
+// outputOptions controls how export output streams are opened.
+type outputOptions struct {
+	compress   string
+	bufSize    int
+	flushEvery int
+	progress   bool
+	// failures, when not nil, collects the tables that failed instead of
+	// stopping the export at the first one (--continue-on-error).
+	failures map[string]error
+}
+
+// progressInterval is how often a running table export reports progress.
+const progressInterval = time.Second
+
+// exportProgress reports processed/total rows for one table on stderr. Tables
+// that finish within the first interval print nothing.
+type exportProgress struct {
+	table string
+	total int
+	done  int
+	last  time.Time
+	shown bool
+}
+
+func newExportProgress(table string, total int) *exportProgress {
+	return &exportProgress{table: table, total: total, last: time.Now()}
+}
+
+func (p *exportProgress) row() {
+	p.done++
+	if now := time.Now(); now.Sub(p.last) >= progressInterval {
+		p.last = now
+		p.print()
+	}
+}
+
+func (p *exportProgress) print() {
+	p.shown = true
+	fmt.Fprintf(os.Stderr, "\r  %s: %d/%d rows", p.table, p.done, p.total)
+}
+
+func (p *exportProgress) finish() {
+	if p.shown {
+		p.print()
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// stdoutIsTerminal reports whether stdout is a terminal rather than a pipe
+// or file.
+func stdoutIsTerminal() bool {
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func newExportFormat(name string, query exportQuery, opts jsonlOptions) exportFormat {
+	none := func(io.Writer) error { return nil }
+	switch name {
+	case "csv":
+		return exportFormat{
+			ext:   "csv",
+			begin: func(io.Writer, []string) error { return nil },
+			table: func(q querier, w io.Writer, table string, onRow func() error) (int, error) {
+				return exportTableCSV(q, table, w, query, onRow)
+			},
+			end: none,
+		}
+	case "sql":
+		return exportFormat{
+			ext: "sql",
+			begin: func(w io.Writer, _ []string) error {
+				_, err := io.WriteString(w, "PRAGMA foreign_keys=OFF;\nBEGIN;\n")
+				return err
+			},
+			table: func(q querier, w io.Writer, table string, onRow func() error) (int, error) {
+				return exportTableSQL(q, table, w, query, onRow)
+			},
+			end: func(w io.Writer) error {
+				_, err := io.WriteString(w, "COMMIT;\n")
+				return err
+			},
+		}
+	case "jsonarray":
+		return newJSONArrayFormat(query, opts)
+	default:
+		return exportFormat{
+			ext: "jsonl",
+			begin: func(w io.Writer, tables []string) error {
+				if !opts.header {
+					return nil
+				}
+				return opts.encoder(w).Encode(map[string]any{"header": true, opts.keys.ts: opts.ts, "tables": tables})
+			},
+			table: func(q querier, w io.Writer, table string, onRow func() error) (int, error) {
+				return exportTable(q, table, opts.encoder(w), query, opts, onRow)
+			},
+			end: none,
+		}
+	}
+}
+
+// newJSONArrayFormat writes rows as a JSON array, or with opts.keyed as an
+// object of arrays keyed by table name. Separators are written as rows and
+// tables go by, so nothing is buffered beyond the current row.
+func newJSONArrayFormat(query exportQuery, opts jsonlOptions) exportFormat {
+	opening, closing := "[", "]\n"
+	rowPad := "  "
+	if opts.keyed {
+		opening, closing = "{", "}\n"
+		rowPad = "    "
+	}
+	// sep goes before the i'th element of a list whose elements are
+	// indented by pad.
+	sep := func(i int, pad string) string {
+		s := ""
+		if i > 0 {
+			s = ","
+		}
+		if opts.indent {
+			s += "\n" + pad
+		}
+		return s
+	}
+	// Only keyed output spans several tables in one stream, and a stream is
+	// written by one goroutine, so this needs no lock.
+	tablesWritten := 0
+	return exportFormat{
+		ext: "json",
+		begin: func(w io.Writer, _ []string) error {
+			tablesWritten = 0
+			_, err := io.WriteString(w, opening)
+			return err
+		},
+		table: func(q querier, w io.Writer, table string, onRow func() error) (int, error) {
+			started := false
+			start := func([]string) error {
+				if !opts.keyed {
+					return nil
+				}
+				started = true
+				key, err := json.Marshal(table)
+				if err != nil {
+					return err
+				}
+				colon := ":"
+				if opts.indent {
+					colon = ": "
+				}
+				_, err = fmt.Fprintf(w, "%s%s%s[", sep(tablesWritten, "  "), key, colon)
+				tablesWritten++
+				return err
+			}
+			rows := 0
+			n, err := scanJSONRows(q, table, query, opts.blob, start, func(row map[string]any) error {
+				var b []byte
+				var err error
+				if opts.indent {
+					b, err = json.MarshalIndent(row, rowPad, "  ")
+				} else {
+					b, err = json.Marshal(row)
+				}
+				if err != nil {
+					return err
+				}
+				if _, err := io.WriteString(w, sep(rows, rowPad)); err != nil {
+					return err
+				}
+				rows++
+				if _, err := w.Write(b); err != nil {
+					return err
+				}
+				return onRow()
+			})
+			// The array is closed even after an error, so output kept by
+			// --continue-on-error is still well-formed JSON.
+			if opts.indent && rows > 0 {
+				pad := ""
+				if opts.keyed {
+					pad = "  "
+				}
+				if _, werr := io.WriteString(w, "\n"+pad); werr != nil && err == nil {
+					err = werr
+				}
+			}
+			if started {
+				if _, werr := io.WriteString(w, "]"); werr != nil && err == nil {
+					err = werr
+				}
+			}
+			return n, err
+		},
+		end: func(w io.Writer) error {
+			if opts.indent && tablesWritten > 0 {
+				if _, err := io.WriteString(w, "\n"); err != nil {
+					return err
+				}
+			}
+			_, err := io.WriteString(w, closing)
+			return err
+		},
+	}
+}
+
+// exportToFile writes tables to path (stdout when empty) in format f and
+// returns the number of rows written per table. Tables that a --where
+// predicate cannot apply to are skipped with a warning and left out of the
+// result.
+func exportToFile(q querier, path string, oo outputOptions, f exportFormat, tables []string, query exportQuery) (map[string]int, error) {
+	out, cleanup, err := openOutput(path, oo)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	counts, err := writeExport(q, out, oo, f, tables, query)
+	if err != nil {
+		return nil, err
+	}
+	return counts, cleanup()
+}
+
+// writeExport writes tables to out in format f. The caller flushes out.
+func writeExport(q querier, out *bufio.Writer, oo outputOptions, f exportFormat, tables []string, query exportQuery) (map[string]int, error) {
+	rows := 0
+	var progress *exportProgress
+	onRow := func() error {
+		rows++
+		if progress != nil {
+			progress.row()
+		}
+		if oo.flushEvery > 0 && rows%oo.flushEvery == 0 {
+			return out.Flush()
+		}
+		return nil
+	}
+
+	if err := f.begin(out, tables); err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, tbl := range tables {
+		if column, missing := whereMissingColumn(q, tbl, query.where); missing {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s: no column %s (used by --where)\n", tbl, column)
+			continue
+		}
+		progress = nil
+		if oo.progress {
+			// A failing count means the table itself will fail or be
+			// skipped below, so it just goes without progress.
+			if stmt, err := query.selectSQL(q, tbl); err == nil {
+				if total, err := countRows(q, "("+stmt+")"); err == nil {
+					progress = newExportProgress(tbl, total)
+				}
+			}
+		}
+		n, err := f.table(q, out, tbl, onRow)
+		if progress != nil {
+			progress.finish()
+		}
+		if err != nil {
+			if oo.failures != nil {
+				fmt.Fprintf(os.Stderr, "warning: export %s failed: %v\n", tbl, err)
+				oo.failures[tbl] = err
+				continue
+			}
+			return nil, fmt.Errorf("export %s: %w", tbl, err)
+		}
+		counts[tbl] = n
+	}
+	if err := f.end(out); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// pipeSink hands everything written to it to a consumer reading the other
+// end of an io.Pipe in its own goroutine, such as an HTTP request body.
+type pipeSink struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+	err  error
+}
+
+// startPipeSink runs consume, which reads pr until EOF, in the background.
+// Once it returns, pending and later writes fail with its error.
+func startPipeSink(pr *io.PipeReader, pw *io.PipeWriter, consume func() error) *pipeSink {
+	s := &pipeSink{pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		s.err = consume()
+		pr.CloseWithError(s.err)
+	}()
+	return s
+}
+
+func (s *pipeSink) Write(p []byte) (int, error) {
+	return s.pw.Write(p)
+}
+
+// Close ends the stream and returns the consumer's result.
+func (s *pipeSink) Close() error {
+	s.pw.Close()
+	<-s.done
+	return s.err
+}
+
+// Abort fails the stream with err, so a broken export never reaches the
+// consumer as a complete upload.
+func (s *pipeSink) Abort(err error) {
+	s.pw.CloseWithError(err)
+	<-s.done
+}
+
+// exportToSink writes tables to sink, aborting it if the export fails.
+func exportToSink(q querier, sink *pipeSink, oo outputOptions, f exportFormat, tables []string, query exportQuery) (map[string]int, error) {
+	out, flush, err := wrapOutput(sink, oo)
+	if err != nil {
+		sink.Abort(err)
+		return nil, err
+	}
+	counts, err := writeExport(q, out, oo, f, tables, query)
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		sink.Abort(err)
+		// Writes fail once the consumer has given up; its error says why.
+		if sink.err != nil {
+			return nil, sink.err
+		}
+		return nil, err
+	}
+	if err := sink.Close(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// tableExport is the outcome of exporting one table with --out-dir.
+type tableExport struct {
+	table   string
+	path    string
+	rows    int
+	skipped bool
+	err     error
+}
+
+// exportTableFile writes table to its own file in dir. Tables that do not
+// exist, or that a --where predicate cannot apply to, are skipped and leave
+// no file behind.
+func exportTableFile(q querier, dir string, oo outputOptions, f exportFormat, table string, query exportQuery) tableExport {
+	res := tableExport{table: table}
+	if ok, err := tableExists(q, table); err != nil {
+		res.err = fmt.Errorf("export %s: %w", table, err)
+		return res
+	} else if !ok {
+		res.skipped = true
+		return res
+	}
+	// Names from sqlite_master may hold any character, so none may leave dir.
+	if strings.ContainsAny(table, `/\`) {
+		res.err = fmt.Errorf("export %s: table name is not a valid file name", table)
+		return res
+	}
+
+	res.path = filepath.Join(dir, table+"."+f.ext+compressExt(oo.compress))
+	counts, err := exportToFile(q, res.path, oo, f, []string{table}, query)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	n, ok := counts[table]
+	if !ok {
+		os.Remove(res.path)
+		res.skipped = true
+		return res
+	}
+	res.rows = n
+	return res
+}
+
+// exportConcurrently runs export for each table on a pool of workers, each
+// table in its own read-only transaction, and returns the results in table
+// order. A failing table is recorded in its result and does not stop the
+// others.
+func exportConcurrently(ctx context.Context, database *sql.DB, workers int, tables []string, export func(q querier, table string) tableExport) []tableExport {
+	results := make([]tableExport, len(tables))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = exportInTx(ctx, database, tables[i], export)
+			}
+		}()
+	}
+	for i := range tables {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func exportInTx(ctx context.Context, database *sql.DB, table string, export func(q querier, table string) tableExport) tableExport {
+	tx, err := database.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return tableExport{table: table, err: fmt.Errorf("export %s: %w", table, err)}
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("PRAGMA query_only = ON"); err != nil {
+		return tableExport{table: table, err: fmt.Errorf("export %s: %w", table, err)}
+	}
+	defer tx.Exec("PRAGMA query_only = OFF")
+	return export(ctxQuerier{ctx: ctx, q: tx}, table)
+}
+
+// jsonlOptions controls the envelope written around each JSONL row.
+type jsonlOptions struct {
+	ts       int64
+	header   bool
+	legacyTS bool
+	raw      bool
+	// keyed makes jsonarray write an object of per-table arrays rather
+	// than a bare array; it is set when one stream holds several tables.
+	keyed bool
+	// indent pretty-prints each object over several lines.
+	indent bool
+	// schema writes a --with-schema object before each table's rows.
+	schema bool
+	// blob is the --blob encoding for []byte values.
+	blob string
+	keys envelopeKeys
+}
+
+// envelopeKeys names the fields of the JSONL envelope.
+type envelopeKeys struct {
+	table, row, ts string
+}
+
+var defaultEnvelopeKeys = envelopeKeys{table: "table", row: "row", ts: "ts"}
+
+// validate rejects empty or repeated field names, which would drop data
+// from the envelope.
+func (k envelopeKeys) validate() error {
+	if k.table == "" || k.row == "" || k.ts == "" {
+		return fmt.Errorf("--key-table, --key-row and --key-ts must not be empty")
+	}
+	if k.table == k.row || k.table == k.ts || k.row == k.ts {
+		return fmt.Errorf("--key-table, --key-row and --key-ts must be distinct")
+	}
+	return nil
+}
+
+// encoder returns a JSON encoder for w that honours --indent.
+func (o jsonlOptions) encoder(w io.Writer) *json.Encoder {
+	enc := json.NewEncoder(w)
+	if o.indent {
+		enc.SetIndent("", "  ")
+	}
+	return enc
+}
+
+// exportQuery shapes the SELECT issued for each exported table.
+type exportQuery struct {
+	columns map[string][]string
+	where   string
+	// orderBy holds per-table ORDER BY columns; order applies to every other
+	// table. An empty order leaves rows in SQLite's natural order.
+	orderBy map[string][]string
+	order   []string
+	// redact replaces the listed columns' values with redactedValue; drop
+	// leaves them out of the output.
+	redact map[string][]string
+	drop   map[string][]string
+	// limit caps the rows taken from each table after skipping offset; 0
+	// means no limit.
+	limit  int
+	offset int
+}
+
+// splitEmptyTables separates tables that have rows from those that have
+// none, keeping the order of tables.
+func splitEmptyTables(q querier, tables []string) (nonEmpty, empty []string, err error) {
+	for _, t := range tables {
+		var hasRows bool
+		if err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM " + quoteIdent(t) + ")").Scan(&hasRows); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", t, err)
+		}
+		if hasRows {
+			nonEmpty = append(nonEmpty, t)
+		} else {
+			empty = append(empty, t)
+		}
+	}
+	return nonEmpty, empty, nil
+}
+
+// sinceNumberRe matches the --since-value values compared as numbers. It is
+// stricter than strconv.ParseFloat, which also accepts NaN, Inf and 1_000,
+// none of which SQLite reads as a number.
+var sinceNumberRe = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// sincePredicate adds the --since-column condition to the --where predicate.
+// Decimal watermarks are compared as numbers and anything else as text.
+func sincePredicate(where, column, value string) string {
+	if column == "" || value == "" {
+		return where
+	}
+	lit := sqlLiteral(value)
+	if sinceNumberRe.MatchString(value) {
+		lit = value
+	}
+	cond := quoteIdent(column) + " > " + lit
+	if where == "" {
+		return cond
+	}
+	return "(" + where + ") AND " + cond
+}
+
+// tablesWithColumn drops the tables that lack column, with a warning.
+func tablesWithColumn(q querier, tables []string, column string) ([]string, error) {
+	var out []string
+	for _, t := range tables {
+		cols, err := tableColumns(q, t)
+		if err != nil {
+			return nil, err
+		}
+		if !containsString(cols, column) {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s: no column %s\n", t, column)
+			continue
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// whereMissingColumn reports whether where refers to a column that table
+// does not have, and names it. --where applies to every exported table, so
+// such tables are skipped rather than failing the export.
+func whereMissingColumn(q querier, table, where string) (string, bool) {
+	if where == "" {
+		return "", false
+	}
+	var one int
+	err := q.QueryRow("SELECT 1 FROM " + quoteIdent(table) + " WHERE " + where + " LIMIT 0").Scan(&one)
+	if err == nil || err == sql.ErrNoRows {
+		return "", false
+	}
+	_, column, ok := strings.Cut(err.Error(), "no such column: ")
+	if !ok {
+		return "", false
+	}
+	// The driver appends the result code, as in "no such column: ts (1)".
+	if i := strings.LastIndex(column, " ("); i >= 0 && strings.HasSuffix(column, ")") {
+		column = column[:i]
+	}
+	cols, err := tableColumns(q, table)
+	if err != nil || firstColumn(cols, []string{column}) != "" {
+		return "", false
+	}
+	return column, true
+}
+
+// sinceWatermark returns the highest value of column among the rows that
+// where selects, across tables, or nil if no row matches. Values compare
+// as SQLite orders them, so numbers sort before text.
+func sinceWatermark(q querier, tables []string, column, where string) (any, error) {
+	var mark any
+	for _, t := range tables {
+		stmt := "SELECT max(" + quoteIdent(column) + ") FROM " + quoteIdent(t)
+		if where != "" {
+			stmt += " WHERE " + where
+		}
+		if _, missing := whereMissingColumn(q, t, where); missing {
+			// The export skips this table too.
+			continue
+		}
+		var v any
+		if err := q.QueryRow(stmt).Scan(&v); err != nil {
+			return nil, fmt.Errorf("watermark for %s: %w", t, err)
+		}
+		if v == nil {
+			continue
+		}
+		if mark == nil {
+			mark = v
+			continue
+		}
+		var greater bool
+		if err := q.QueryRow("SELECT ? > ?", v, mark).Scan(&greater); err != nil {
+			return nil, err
+		}
+		if greater {
+			mark = v
+		}
+	}
+	return mark, nil
+}
+
+// redactedValue stands in for the values of --redact columns.
+const redactedValue = "[REDACTED]"
+
+func (q exportQuery) selectSQL(database querier, table string) (string, error) {
+	var have []string
+	loadColumns := func() error {
+		if have != nil {
+			return nil
+		}
+		var err error
+		have, err = tableColumns(database, table)
+		return err
+	}
+	checkColumn := func(c string) error {
+		if err := loadColumns(); err != nil {
+			return err
+		}
+		if !containsString(have, c) {
+			return fmt.Errorf("unknown column %q in %s (columns: %s)", c, table, strings.Join(have, ", "))
+		}
+		return nil
+	}
+
+	sel := "*"
+	want, ok := q.columns[table]
+	if ok || len(q.redact[table]) > 0 || len(q.drop[table]) > 0 {
+		if !ok {
+			if err := loadColumns(); err != nil {
+				return "", err
+			}
+			want = have
+		}
+		var quoted []string
+		for _, c := range want {
+			if err := checkColumn(c); err != nil {
+				return "", err
+			}
+			switch {
+			case containsString(q.drop[table], c):
+			case containsString(q.redact[table], c):
+				quoted = append(quoted, sqlLiteral(redactedValue)+" AS "+quoteIdent(c))
+			default:
+				quoted = append(quoted, quoteIdent(c))
+			}
+		}
+		if len(quoted) == 0 {
+			return "", fmt.Errorf("every exported column of %s is dropped", table)
+		}
+		sel = strings.Join(quoted, ", ")
+	}
+	stmt := "SELECT " + sel + " FROM " + quoteIdent(table)
+	if q.where != "" {
+		stmt += " WHERE " + q.where
+	}
+
+	order, ok := q.orderBy[table]
+	if !ok {
+		order = q.order
+	}
+	if len(order) == 1 && order[0] == "rowid" {
+		// WITHOUT ROWID tables have no rowid; their primary key gives the
+		// same stable order.
+		var withoutRowid bool
+		if err := database.QueryRow(`SELECT wr FROM pragma_table_list(?) WHERE schema = 'main'`, table).Scan(&withoutRowid); err != nil {
+			return "", err
+		}
+		if withoutRowid {
+			_, pk, err := schemaColumns(database, "main", table)
+			if err != nil {
+				return "", err
+			}
+			order = pk
+		}
+	}
+	if len(order) > 0 {
+		quoted := make([]string, len(order))
+		for i, c := range order {
+			if c != "rowid" {
+				if err := checkColumn(c); err != nil {
+					return "", err
+				}
+			}
+			// Qualified, so a redacted column sorts by its real value rather
+			// than the constant that replaces it.
+			quoted[i] = quoteIdent(table) + "." + quoteIdent(c)
+		}
+		stmt += " ORDER BY " + strings.Join(quoted, ", ")
+	}
+	if q.limit > 0 || q.offset > 0 {
+		limit := q.limit
+		if limit == 0 {
+			limit = -1
+		}
+		stmt += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, q.offset)
+	}
+	return stmt, nil
+}
+
+// checkColumnRefs fails unless every table:column named by flag exists, so a
+// typo in --redact or --drop-columns cannot let a column through unchanged.
+func checkColumnRefs(q querier, flag string, refs map[string][]string) error {
+	for table, cols := range refs {
+		have, err := tableColumns(q, table)
+		if err != nil {
+			return err
+		}
+		if len(have) == 0 {
+			return fmt.Errorf("%s: no such table: %s", flag, table)
+		}
+		for _, c := range cols {
+			if !containsString(have, c) {
+				return fmt.Errorf("%s: unknown column %q in %s (columns: %s)", flag, c, table, strings.Join(have, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// parseOrderSpec parses --order-by: "table:col1,col2" entries separated by
+// semicolons, plus at most one entry without a table that applies to all
+// other tables. "none" as that entry disables ordering.
+func parseOrderSpec(spec string) (perTable map[string][]string, order []string, err error) {
+	perTable = map[string][]string{}
+	blanket := false
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		table, cols, ok := strings.Cut(part, ":")
+		if !ok {
+			if blanket {
+				return nil, nil, fmt.Errorf("invalid order spec %q: more than one default ordering", spec)
+			}
+			blanket = true
+			if part != "none" {
+				order = parseTableList(part)
+			}
+			continue
+		}
+		table = strings.TrimSpace(table)
+		list := parseTableList(cols)
+		if table == "" || len(list) == 0 {
+			return nil, nil, fmt.Errorf("invalid order spec %q (want table:col1,col2, rowid or none)", part)
+		}
+		perTable[table] = append(perTable[table], list...)
+	}
+	return perTable, order, nil
+}
+
+// parseColumnSpec parses "table:col1,col2;other:col" into per-table column
+// lists, preserving the requested order.
+func parseColumnSpec(spec string) (map[string][]string, error) {
+	out := map[string][]string{}
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		table, cols, ok := strings.Cut(part, ":")
+		table = strings.TrimSpace(table)
+		list := parseTableList(cols)
+		if !ok || table == "" || len(list) == 0 {
+			return nil, fmt.Errorf("invalid column spec %q (want table:col1,col2)", part)
+		}
+		out[table] = append(out[table], list...)
+	}
+	return out, nil
+}
+
+// schemaColumn is one entry of a --with-schema object. Type is the declared
+// type, which is empty for untyped columns.
+type schemaColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// exportSchema describes the exported columns cols of table, in result
+// order. Columns table_info does not list, such as expressions, get no type.
+func exportSchema(database querier, table string, cols []string) ([]schemaColumn, error) {
+	rows, err := database.Query(`SELECT name, type FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	declared := map[string]string{}
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return nil, err
+		}
+		declared[name] = typ
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	schema := make([]schemaColumn, len(cols))
+	for i, c := range cols {
+		schema[i] = schemaColumn{Name: c, Type: declared[c]}
+	}
+	return schema, nil
+}
+
+func tableColumns(database querier, table string) ([]string, error) {
+	rows, err := database.Query(`SELECT name FROM pragma_table_info(?) ORDER BY cid`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		cols = append(cols, name)
+	}
+	return cols, rows.Err()
+}
+
+// firstColumn returns the first of candidates that is in cols, spelled as
+// in cols, or "". SQLite column names are case-insensitive, so the match is
+// too.
+func firstColumn(cols, candidates []string) string {
+	for _, want := range candidates {
+		for _, c := range cols {
+			if strings.EqualFold(c, want) {
+				return c
+			}
+		}
+	}
+	return ""
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func exportTableCSV(database querier, table string, w io.Writer, query exportQuery, onRow func() error) (int, error) {
+	if ok, err := tableExists(database, table); err != nil || !ok {
+		return 0, err
+	}
+
+	stmt, err := query.selectSQL(database, table)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := database.Query(stmt)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if err := writeCSVRecord(w, cols, nil); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	record := make([]string, len(cols))
+	null := make([]bool, len(cols))
+	for rows.Next() {
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return n, err
+		}
+
+		for i, v := range vals {
+			record[i] = csvValue(v)
+			null[i] = v == nil
+		}
+		if err := writeCSVRecord(w, record, null); err != nil {
+			return n, err
+		}
+		n++
+		if err := onRow(); err != nil {
+			return n, err
+		}
+	}
+	return n, rows.Err()
+}
+
+// writeCSVRecord writes one CSV record to w. Fields marked in null are
+// written empty and unquoted; an empty string is written as "" so that it
+// reads back differently from NULL, which encoding/csv cannot do.
+func writeCSVRecord(w io.Writer, fields []string, null []bool) error {
+	var b strings.Builder
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		switch {
+		case null != nil && null[i]:
+		case f == "", f == `\.`, strings.ContainsAny(f, ",\"\r\n"), f[0] == ' ', f[0] == '\t':
+			b.WriteByte('"')
+			b.WriteString(strings.ReplaceAll(f, `"`, `""`))
+			b.WriteByte('"')
+		default:
+			b.WriteString(f)
+		}
+	}
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// csvValue renders a scanned column value as a CSV field. NULL becomes an
+// empty field rather than the string "null".
+func csvValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// exportTableSQL writes the table's CREATE statement followed by batched
+// INSERT statements. The caller wraps the dump in BEGIN/COMMIT.
+func exportTableSQL(database querier, table string, w io.Writer, query exportQuery, onRow func() error) (int, error) {
+	if ok, err := tableExists(database, table); err != nil || !ok {
+		return 0, err
+	}
+
+	var ddl sql.NullString
+	if err := database.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name=?`, table).Scan(&ddl); err != nil {
+		return 0, err
+	}
+
+	stmt, err := query.selectSQL(database, table)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := database.Query(stmt)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	if ddl.Valid {
+		if _, err := fmt.Fprintf(w, "%s;\n", ddl.String); err != nil {
+			return 0, err
+		}
+	}
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	quoted := make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = quoteIdent(c)
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES\n", quoteIdent(table), strings.Join(quoted, ", "))
+
+	n := 0
+	lits := make([]string, len(cols))
+	for rows.Next() {
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return n, err
+		}
+		for i, v := range vals {
+			lits[i] = sqlLiteral(v)
+		}
+
+		sep := ",\n"
+		if n%sqlInsertBatch == 0 {
+			if n > 0 {
+				if _, err := io.WriteString(w, ";\n"); err != nil {
+					return n, err
+				}
+			}
+			sep = insert
+		}
+		if _, err := fmt.Fprintf(w, "%s  (%s)", sep, strings.Join(lits, ", ")); err != nil {
+			return n, err
+		}
+		n++
+		if err := onRow(); err != nil {
+			return n, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	if n > 0 {
+		if _, err := io.WriteString(w, ";\n"); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// sqlLiteral renders a scanned column value as a SQLite literal.
+func sqlLiteral(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return "X'" + hex.EncodeToString(v) + "'"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		return "'" + v.Format(time.RFC3339Nano) + "'"
+	default:
+		return "'" + strings.ReplaceAll(fmt.Sprint(v), "'", "''") + "'"
+	}
+}
+
+// quoteIdent quotes a SQLite identifier, doubling embedded quotes.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// blobMarker and hexMarker are the keys of the objects that wrap binary
+// values in JSON output, e.g. {"$b64":"AAE="} or, with --blob hex,
+// {"$hex":"0001"}.
+const (
+	blobMarker = "$b64"
+	hexMarker  = "$hex"
+)
+
+// Values for export --blob.
+const (
+	blobAuto   = "auto"
+	blobBase64 = "base64"
+	blobHex    = "hex"
+	blobString = "string"
+)
+
+// blobValue converts a []byte value for JSON encoding. In auto mode BLOB
+// columns are always base64-wrapped, and other columns are written as text
+// unless the bytes are not valid UTF-8. string forces text, which is lossy
+// for binary data. NULL scans as an untyped nil, never as a []byte, so a nil
+// b is an empty BLOB.
+func blobValue(b []byte, declType, mode string) any {
+	switch mode {
+	case blobBase64:
+		return map[string]string{blobMarker: base64.StdEncoding.EncodeToString(b)}
+	case blobHex:
+		return map[string]string{hexMarker: hex.EncodeToString(b)}
+	case blobString:
+		return jsonValue(string(b), declType)
+	}
+	if columnAffinity(declType) == "BLOB" || !utf8.Valid(b) {
+		return map[string]string{blobMarker: base64.StdEncoding.EncodeToString(b)}
+	}
+	return jsonValue(string(b), declType)
+}
+
+// jsonValue converts a scanned value for JSON encoding according to the
+// column's declared type, so INTEGER and REAL columns encode as numbers even
+// when a value was stored as text, and binary data survives as base64.
+func jsonValue(v any, declType string) any {
+	affinity := columnAffinity(declType)
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return blobValue(v, declType, blobAuto)
+	case string:
+		switch affinity {
+		case "INTEGER", "NUMERIC":
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return jsonValue(n, declType)
+			}
+			if f, err := strconv.ParseFloat(v, 64); err == nil && affinity == "NUMERIC" {
+				return f
+			}
+		case "REAL":
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f
+			}
+		}
+		return v
+	case int64:
+		if strings.Contains(strings.ToUpper(declType), "BOOL") && (v == 0 || v == 1) {
+			return v == 1
+		}
+		if affinity == "REAL" {
+			return float64(v)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// columnAffinity applies SQLite's type affinity rules to a declared type.
+func columnAffinity(declType string) string {
+	t := strings.ToUpper(declType)
+	switch {
+	case strings.Contains(t, "INT"):
+		return "INTEGER"
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return "TEXT"
+	case t == "", strings.Contains(t, "BLOB"):
+		return "BLOB"
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"):
+		return "REAL"
+	default:
+		return "NUMERIC"
+	}
+}
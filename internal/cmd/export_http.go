@@ -0,0 +1,131 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// httpAttempts bounds how often an export is POSTed before a 5xx response
+// is treated as final. Attempts are spaced by httpBackoff, doubling each time.
+const (
+	httpAttempts = 4
+	httpBackoff  = time.Second
+)
+
+// exportContentTypes maps an export format's extension to the Content-Type
+// sent with --out-url.
+var exportContentTypes = map[string]string{
+	"jsonl": "application/x-ndjson",
+	"json":  "application/json",
+	"csv":   "text/csv",
+	"sql":   "application/sql",
+}
+
+// httpStatusError is a non-2xx response to an export POST.
+type httpStatusError struct {
+	url    string
+	status string
+	code   int
+	body   string
+}
+
+func (e *httpStatusError) Error() string {
+	msg := fmt.Sprintf("POST %s: %s", e.url, e.status)
+	if e.body != "" {
+		msg += ": " + e.body
+	}
+	return msg
+}
+
+// openHTTPOutput starts a chunked POST of everything written to the returned
+// sink, so the export is sent as it is produced without touching disk.
+func openHTTPOutput(ctx context.Context, url string, header http.Header) (*pipeSink, error) {
+	pr, pw := io.Pipe()
+	// The transport closes the body it was given once a response arrives;
+	// keep pr open so the response status, not io.ErrClosedPipe, is what
+	// pending writes see.
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, io.NopCloser(pr))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header.Clone()
+
+	return startPipeSink(pr, pw, func() error {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		return checkHTTPResponse(url, resp)
+	}), nil
+}
+
+func checkHTTPResponse(url string, resp *http.Response) error {
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &httpStatusError{url: url, status: resp.Status, code: resp.StatusCode, body: strings.TrimSpace(string(b))}
+}
+
+// exportToURL POSTs tables to url in format f, retrying with exponential
+// backoff while the server answers 5xx. Each attempt re-reads the tables from
+// q, which the caller keeps in one transaction, so retries send the same
+// data. 4xx responses and other errors fail immediately.
+func exportToURL(ctx context.Context, q querier, url string, header http.Header, oo outputOptions, f exportFormat, tables []string, query exportQuery) (map[string]int, error) {
+	header = header.Clone()
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", exportContentTypes[f.ext])
+	}
+	if oo.compress != compressNone {
+		header.Set("Content-Encoding", oo.compress)
+	}
+
+	delay := httpBackoff
+	for attempt := 1; ; attempt++ {
+		counts, err := postExport(ctx, q, url, header, oo, f, tables, query)
+		var status *httpStatusError
+		if err == nil || !errors.As(err, &status) || status.code < 500 || attempt == httpAttempts {
+			return counts, err
+		}
+		fmt.Fprintf(os.Stderr, "warning: %v; retrying in %s\n", err, delay)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func postExport(ctx context.Context, q querier, url string, header http.Header, oo outputOptions, f exportFormat, tables []string, query exportQuery) (map[string]int, error) {
+	sink, err := openHTTPOutput(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+	return exportToSink(q, sink, oo, f, tables, query)
+}
+
+// parseHeaders parses "Name: value" flags into an http.Header.
+func parseHeaders(list []string) (http.Header, error) {
+	header := http.Header{}
+	for _, h := range list {
+		name, value, ok := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q (want \"Name: value\")", h)
+		}
+		header.Add(name, strings.TrimSpace(value))
+	}
+	return header, nil
+}
@@ -0,0 +1,18 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+//go:build !s3
+
+package cmd
+
+import (
+	"context"
+	"errors"
+)
+
+var errS3Unsupported = errors.New("this arc-db was built without S3 support; rebuild with -tags s3")
+
+// openS3Output is the stub used when the AWS SDK is not compiled in.
+func openS3Output(ctx context.Context, uri, contentType string) (*pipeSink, error) {
+	return nil, errS3Unsupported
+}
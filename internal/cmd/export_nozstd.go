@@ -0,0 +1,21 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+//go:build !zstd
+
+package cmd
+
+import "io"
+
+// zstdSupported reports whether --compress zstd and .zst imports can be used.
+const zstdSupported = false
+
+// newZstdWriter is the stub used when the zstd encoder is not compiled in.
+func newZstdWriter(w io.Writer) (io.WriteCloser, error) {
+	return nil, errZstdUnsupported
+}
+
+// newZstdReader is the stub used when the zstd decoder is not compiled in.
+func newZstdReader(r io.Reader) (io.ReadCloser, error) {
+	return nil, errZstdUnsupported
+}
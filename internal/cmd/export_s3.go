@@ -0,0 +1,61 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+//go:build s3
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// openS3Output streams everything written to the returned sink to an
+// s3://bucket/key URI as a multipart upload, so no local copy is needed.
+// Credentials come from the standard AWS environment, shared config and
+// instance role chain. An aborted sink aborts the multipart upload.
+func openS3Output(ctx context.Context, uri, contentType string) (*pipeSink, error) {
+	bucket, key, err := parseS3URI(uri)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load AWS config: %w", err)
+	}
+	uploader := manager.NewUploader(s3.NewFromConfig(cfg))
+
+	pr, pw := io.Pipe()
+	return startPipeSink(pr, pw, func() error {
+		_, err := uploader.Upload(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(bucket),
+			Key:         aws.String(key),
+			Body:        pr,
+			ContentType: aws.String(contentType),
+		})
+		if err != nil {
+			return fmt.Errorf("upload %s: %w", uri, err)
+		}
+		return nil
+	}), nil
+}
+
+func parseS3URI(uri string) (bucket, key string, err error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", "", err
+	}
+	key = strings.TrimPrefix(u.Path, "/")
+	if u.Scheme != "s3" || u.Host == "" || key == "" {
+		return "", "", fmt.Errorf("invalid S3 URI %q (want s3://bucket/key)", uri)
+	}
+	return u.Host, key, nil
+}
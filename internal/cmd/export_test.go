@@ -0,0 +1,335 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestDB opens an in-memory database and runs stmts against it.
+func newTestDB(t testing.TB, stmts ...string) *sql.DB {
+	t.Helper()
+	database, err := openMemory(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	for _, s := range stmts {
+		if _, err := database.Exec(s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+	return database
+}
+
+// testJSONLOptions is the JSONL envelope export writes by default, with a
+// fixed timestamp so output can be compared.
+var testJSONLOptions = jsonlOptions{ts: 1700000000, blob: blobAuto, keys: defaultEnvelopeKeys}
+
+// exportString exports tables in format with the default row order and
+// returns the output.
+func exportString(t testing.TB, q querier, format string, tables ...string) string {
+	t.Helper()
+	out, err := tryExport(q, format, exportQuery{order: []string{"rowid"}}, tables...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func tryExport(q querier, format string, query exportQuery, tables ...string) (string, error) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	f := newExportFormat(format, query, testJSONLOptions)
+	if _, err := writeExport(q, w, outputOptions{}, f, tables, query); err != nil {
+		return "", err
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func TestExportQuotesTableNames(t *testing.T) {
+	database := newTestDB(t,
+		`CREATE TABLE "order" (id INTEGER, "group" TEXT)`,
+		`INSERT INTO "order" VALUES (1, 'a')`,
+		`CREATE TABLE "my-table" (id INTEGER)`,
+		`INSERT INTO "my-table" VALUES (2)`,
+		`CREATE TABLE "audit log" (id INTEGER)`,
+		`INSERT INTO "audit log" VALUES (3)`,
+		`CREATE TABLE "v1.events" (id INTEGER)`,
+		`INSERT INTO "v1.events" VALUES (4)`,
+	)
+
+	// Names found in sqlite_master export whatever they contain.
+	tests := []struct {
+		table string
+		want  string
+	}{
+		{table: "order", want: `{"row":{"group":"a","id":1},"table":"order","ts":1700000000}` + "\n"},
+		{table: "my-table", want: `{"row":{"id":2},"table":"my-table","ts":1700000000}` + "\n"},
+		{table: "audit log", want: `{"row":{"id":3},"table":"audit log","ts":1700000000}` + "\n"},
+		{table: "v1.events", want: `{"row":{"id":4},"table":"v1.events","ts":1700000000}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			got, err := tryExport(database, "jsonl", exportQuery{order: []string{"rowid"}}, tt.table)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("export = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Names typed on the command line must also pass safeIdentRe.
+	if _, err := validateTables(database, []string{"order", "my-table"}, false); err != nil {
+		t.Errorf("validateTables: %v", err)
+	}
+	for _, table := range []string{`order"; DROP TABLE "order`, "audit log"} {
+		if _, err := validateTables(database, []string{table}, false); err == nil || !strings.Contains(err.Error(), "invalid table name") {
+			t.Errorf("validateTables(%q) err = %v, want invalid table name", table, err)
+		}
+	}
+}
+
+func TestExportableTables(t *testing.T) {
+	database := newTestDB(t,
+		`CREATE TABLE repos (name TEXT)`,
+		`CREATE TABLE "audit log" (id INTEGER)`,
+		`CREATE TABLE schema_migrations (version INTEGER)`,
+		`CREATE TABLE schema_migrations_lock (id INTEGER)`,
+		`CREATE TABLE schema_migrations_archive (version INTEGER)`,
+	)
+
+	tests := []struct {
+		includeMigrations bool
+		want              []string
+	}{
+		{false, []string{"audit log", "repos", "schema_migrations_archive"}},
+		{true, []string{"audit log", "repos", "schema_migrations", "schema_migrations_archive"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("include migrations %v", tt.includeMigrations), func(t *testing.T) {
+			got, err := exportableTables(database, tt.includeMigrations)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("exportableTables = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// exportedRows decodes JSONL export output into each row's raw column
+// values.
+func exportedRows(t testing.TB, out string) []map[string]json.RawMessage {
+	t.Helper()
+	var rows []map[string]json.RawMessage
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var r exportedRow
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		rows = append(rows, r.Row)
+	}
+	return rows
+}
+
+func TestExportJSONTypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		decl  string
+		value string
+		want  string
+	}{
+		{"integer", "INTEGER", "42", `42`},
+		{"negative integer", "INTEGER", "-7", `-7`},
+		{"real", "REAL", "1.5", `1.5`},
+		{"integer in real column", "REAL", "2", `2`},
+		{"text", "TEXT", "'hello'", `"hello"`},
+		{"digits in text column", "TEXT", "'42'", `"42"`},
+		{"digits in integer column", "INTEGER", "CAST('42' AS TEXT)", `42`},
+		{"blob", "BLOB", "x'00ff10'", `{"$b64":"AP8Q"}`},
+		{"binary in text column", "TEXT", "x'ff00'", `{"$b64":"/wA="}`},
+		{"boolean", "BOOLEAN", "1", `true`},
+		{"untyped integer", "", "3", `3`},
+		{"untyped text", "", "'x'", `"x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDB(t,
+				"CREATE TABLE t (v "+tt.decl+")",
+				"INSERT INTO t VALUES ("+tt.value+")",
+			)
+			rows := exportedRows(t, exportString(t, database, "jsonl", "t"))
+			if len(rows) != 1 {
+				t.Fatalf("got %d rows, want 1", len(rows))
+			}
+			if got := string(rows[0]["v"]); got != tt.want {
+				t.Errorf("v = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// benchmarkRows is the table size used by the export and import benchmarks.
+const benchmarkRows = 10000
+
+// newBenchmarkDB returns a database whose table t holds benchmarkRows rows.
+func newBenchmarkDB(b *testing.B) *sql.DB {
+	b.Helper()
+	return newTestDB(b,
+		`CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT, score REAL, created_at INTEGER)`,
+		fmt.Sprintf(`WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < %d)
+			INSERT INTO t SELECT i, 'name-' || i, i / 3.0, 1700000000 + i FROM n`, benchmarkRows),
+	)
+}
+
+// BenchmarkExport compares writing each row straight to the file, as export
+// did before --buffer, with the 64KB default and a larger buffer.
+func BenchmarkExport(b *testing.B) {
+	database := newBenchmarkDB(b)
+	query := exportQuery{order: []string{"rowid"}}
+	f := newExportFormat("jsonl", query, testJSONLOptions)
+	path := filepath.Join(b.TempDir(), "export.jsonl")
+
+	for _, bc := range []struct {
+		name string
+		oo   outputOptions
+	}{
+		{"flush every row", outputOptions{bufSize: 64 * 1024, flushEvery: 1}},
+		{"64KB buffer", outputOptions{bufSize: 64 * 1024, flushEvery: 1000}},
+		{"1MB buffer", outputOptions{bufSize: 1024 * 1024, flushEvery: 1000}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := exportToFile(database, path, bc.oo, f, []string{"t"}, query); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(benchmarkRows*b.N)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+}
+
+func TestExportIsDeterministic(t *testing.T) {
+	// Rows are inserted out of id order and partly rewritten, and the index
+	// gives SQLite another order to scan in, so only ORDER BY keeps the
+	// output stable.
+	database := newTestDB(t,
+		`CREATE TABLE t (id INTEGER, name TEXT, score REAL)`,
+		`CREATE INDEX t_name ON t (name)`,
+		`INSERT INTO t VALUES (3, 'c', 0.5), (1, 'z', NULL), (2, 'a', 2)`,
+		`DELETE FROM t WHERE id = 1`,
+		`INSERT INTO t VALUES (1, 'b', 1.25)`,
+		`CREATE TABLE u (k TEXT PRIMARY KEY, v BLOB) WITHOUT ROWID`,
+		`INSERT INTO u VALUES ('y', x'01'), ('x', x'02')`,
+	)
+
+	tests := []struct {
+		format string
+		tables []string
+		order  string
+		want   string // checked when set
+	}{
+		{"jsonl", []string{"t", "u"}, "rowid;u:k", ""},
+		{"jsonarray", []string{"t"}, "rowid", ""},
+		{"csv", []string{"t"}, "rowid", "id,name,score\n3,c,0.5\n2,a,2\n1,b,1.25\n"},
+		{"csv", []string{"t"}, "t:name,id", "id,name,score\n2,a,2\n1,b,1.25\n3,c,0.5\n"},
+		{"sql", []string{"t", "u"}, "rowid;u:k", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format+" "+tt.order, func(t *testing.T) {
+			orderBy, order, err := parseOrderSpec(tt.order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query := exportQuery{orderBy: orderBy, order: order}
+			first, err := tryExport(database, tt.format, query, tt.tables...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := tryExport(database, tt.format, query, tt.tables...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first == "" {
+				t.Fatal("export is empty")
+			}
+			if first != second {
+				t.Errorf("exports differ:\n%s\n---\n%s", first, second)
+			}
+			if tt.want != "" && first != tt.want {
+				t.Errorf("export = %q, want %q", first, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportNulls(t *testing.T) {
+	// The second row holds the empty value of each class, which must stay
+	// distinguishable from NULL.
+	database := newTestDB(t,
+		`CREATE TABLE t (i INTEGER, r REAL, s TEXT, b BLOB, n NUMERIC, u)`,
+		`INSERT INTO t VALUES (NULL, NULL, NULL, NULL, NULL, NULL)`,
+		`INSERT INTO t VALUES (0, 0.5, '', x'', 1, '')`,
+	)
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"jsonl", `{"row":{"b":null,"i":null,"n":null,"r":null,"s":null,"u":null},"table":"t","ts":1700000000}` + "\n" +
+			`{"row":{"b":{"$b64":""},"i":0,"n":1,"r":0.5,"s":"","u":""},"table":"t","ts":1700000000}` + "\n"},
+		{"jsonarray", `[{"b":null,"i":null,"n":null,"r":null,"s":null,"u":null},{"b":{"$b64":""},"i":0,"n":1,"r":0.5,"s":"","u":""}]` + "\n"},
+		{"csv", "i,r,s,b,n,u\n,,,,,\n0,0.5,\"\",\"\",1,\"\"\n"},
+		{"sql", "  (NULL, NULL, NULL, NULL, NULL, NULL),\n  (0, 0.5, '', X'', 1, '');\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got := exportString(t, database, tt.format, "t")
+			if tt.format == "sql" {
+				if !strings.Contains(got, tt.want) {
+					t.Errorf("export = %q, want it to contain %q", got, tt.want)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("export = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// NULLs must also come back as NULL, not as empty values.
+	dst := newTestDB(t, `CREATE TABLE t (i INTEGER, r REAL, s TEXT, b BLOB, n NUMERIC, u)`)
+	if err := newImporter("insert", nil).run(dst, strings.NewReader(exportString(t, database, "jsonl", "t"))); err != nil {
+		t.Fatal(err)
+	}
+	var nulls int
+	if err := dst.QueryRow(`SELECT (i IS NULL) + (r IS NULL) + (s IS NULL) + (b IS NULL) + (n IS NULL) + (u IS NULL) FROM t WHERE rowid = 1`).Scan(&nulls); err != nil {
+		t.Fatal(err)
+	}
+	if nulls != 6 {
+		t.Errorf("%d of 6 NULLs survived import", nulls)
+	}
+	var empties int
+	if err := dst.QueryRow(`SELECT count(*) FROM t WHERE rowid = 2 AND s = '' AND b = x'' AND u = ''`).Scan(&empties); err != nil {
+		t.Fatal(err)
+	}
+	if empties != 1 {
+		t.Error("empty values came back as NULL")
+	}
+}
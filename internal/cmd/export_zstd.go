@@ -0,0 +1,30 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+//go:build zstd
+
+package cmd
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdSupported reports whether --compress zstd and .zst imports can be used.
+const zstdSupported = true
+
+// newZstdWriter returns a zstd encoder writing to w. Close must be called
+// to write the final frame.
+func newZstdWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w)
+}
+
+// newZstdReader returns a zstd decoder reading from r.
+func newZstdReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
@@ -0,0 +1,275 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const externalReposTable = "external_repos"
+
+// externalRepo is one external_repos row to check.
+type externalRepo struct {
+	rowid int64
+	url   string
+	err   error
+}
+
+func newExternalReposCmd() *cobra.Command {
+	ec := &cobra.Command{
+		Use:   "external-repos",
+		Short: "Maintain the external_repos table",
+		RunE:  func(cmd *cobra.Command, args []string) error { return cmd.Help() },
+	}
+	ec.AddCommand(newExternalReposValidateCmd())
+	return ec
+}
+
+func newExternalReposValidateCmd() *cobra.Command {
+	var timeout time.Duration
+	var concurrency int
+	var prune, yes bool
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Report external repos whose URL is unreachable",
+		Long: `Check that every URL in external_repos can still be reached, --concurrency
+at a time. http(s) URLs get a HEAD request (retried as GET if the server
+does not allow HEAD) and must answer below 400; other URLs, such as
+git@host:org/repo, are checked with git ls-remote. Each check fails after
+--timeout. Exits non-zero if any repo fails the check.
+
+Rows with an empty or NULL url are reported separately. Repos that refuse
+the check for lack of credentials (HTTP 401 or 403, or a git error such as
+"Authentication failed" or "Repository not found", which hosts also return
+for private repos) are reported as needing credentials rather than
+unreachable.
+
+--prune deletes the unreachable rows, never those without a url or needing
+credentials. It lists them first and requires --yes, since a transient
+network failure looks the same as a deleted repo.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if concurrency < 1 {
+				return fmt.Errorf("--concurrency must be at least 1")
+			}
+
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			if ok, err := checkTable(database, externalReposTable); err != nil {
+				return err
+			} else if !ok {
+				return fmt.Errorf("no %s table (run arc-db migrate up)", externalReposTable)
+			}
+			rows, err := database.Query(`SELECT rowid, url FROM ` + externalReposTable + ` ORDER BY rowid`)
+			if err != nil {
+				return err
+			}
+			var repos []externalRepo
+			for rows.Next() {
+				var r externalRepo
+				var url *string
+				if err := rows.Scan(&r.rowid, &url); err != nil {
+					rows.Close()
+					return err
+				}
+				if url != nil {
+					r.url = *url
+				}
+				repos = append(repos, r)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			if len(repos) == 0 {
+				fmt.Println("No external repos.")
+				return nil
+			}
+
+			jobs := make(chan int)
+			var wg sync.WaitGroup
+			for w := 0; w < concurrency; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range jobs {
+						repos[i].err = checkRepoReachable(cmd.Context(), repos[i].url, timeout)
+					}
+				}()
+			}
+			for i := range repos {
+				jobs <- i
+			}
+			close(jobs)
+			wg.Wait()
+
+			var unreachable []externalRepo
+			var noURL, needsAuth int
+			for _, r := range repos {
+				switch {
+				case r.err == nil:
+				case errors.Is(r.err, errRepoNoURL):
+					noURL++
+					fmt.Printf("  no url: rowid %d\n", r.rowid)
+				case errors.Is(r.err, errRepoAuth):
+					needsAuth++
+					fmt.Printf("  needs credentials: %s: %v\n", r.url, r.err)
+				default:
+					unreachable = append(unreachable, r)
+					fmt.Printf("  unreachable: %s: %v\n", r.url, r.err)
+				}
+			}
+			checked := len(repos) - noURL
+			fmt.Printf("%d of %d repo(s) reachable\n", checked-needsAuth-len(unreachable), checked)
+			if noURL > 0 {
+				fmt.Printf("%d repo(s) have no url\n", noURL)
+			}
+			if needsAuth > 0 {
+				fmt.Printf("%d repo(s) need credentials to check\n", needsAuth)
+			}
+			if !prune || len(unreachable) == 0 {
+				if n := len(unreachable) + noURL + needsAuth; n > 0 {
+					return fmt.Errorf("%d repo(s) failed validation", n)
+				}
+				return nil
+			}
+			if !yes {
+				return fmt.Errorf("refusing to delete %d unreachable repo(s) without --yes", len(unreachable))
+			}
+
+			tx, err := database.Begin()
+			if err != nil {
+				return err
+			}
+			defer tx.Rollback()
+			for _, r := range unreachable {
+				if _, err := execLogged(cmd.Context(), tx, `DELETE FROM `+externalReposTable+` WHERE rowid = ?`, r.rowid); err != nil {
+					return err
+				}
+			}
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			fmt.Printf("Deleted %d unreachable repo(s)\n", len(unreachable))
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Second, "Give up on a repo after this long")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 8, "Check up to this many repos at once")
+	cmd.Flags().BoolVar(&prune, "prune", false, "Delete the unreachable repos")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm deleting with --prune")
+
+	return cmd
+}
+
+var (
+	// errRepoNoURL marks rows whose url is empty or NULL.
+	errRepoNoURL = errors.New("empty url")
+	// errRepoAuth marks repos that refused the check for lack of
+	// credentials, which says nothing about whether they still exist.
+	errRepoAuth = errors.New("needs credentials")
+)
+
+// gitAuthErrors are git ls-remote messages, lower-cased, that mean the
+// remote wanted credentials. GitHub and GitLab answer "Repository not found"
+// for private repos too.
+var gitAuthErrors = []string{
+	"authentication failed",
+	"could not read username",
+	"could not read password",
+	"terminal prompts disabled",
+	"permission denied",
+	"access denied",
+	"repository not found",
+	"the requested url returned error: 401",
+	"the requested url returned error: 403",
+}
+
+// checkRepoReachable returns nil if url answers within timeout. The error
+// wraps errRepoNoURL or errRepoAuth when the url is missing or the remote
+// wants credentials.
+func checkRepoReachable(ctx context.Context, url string, timeout time.Duration) error {
+	if strings.TrimSpace(url) == "" {
+		return errRepoNoURL
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		return checkHTTPReachable(ctx, url)
+	}
+	return checkGitReachable(ctx, url)
+}
+
+func checkHTTPReachable(ctx context.Context, url string) error {
+	status, err := httpStatus(ctx, http.MethodHead, url)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = httpStatus(ctx, http.MethodGet, url)
+	}
+	if err != nil {
+		return err
+	}
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		return fmt.Errorf("HTTP %d: %w", status, errRepoAuth)
+	}
+	if status >= 400 {
+		return fmt.Errorf("HTTP %d", status)
+	}
+	return nil
+}
+
+func httpStatus(ctx context.Context, method, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// checkGitReachable runs git ls-remote without prompting for credentials,
+// so private repos without configured access fail with errRepoAuth.
+func checkGitReachable(ctx context.Context, url string) error {
+	c := exec.CommandContext(ctx, "git", "ls-remote", "--", url, "HEAD")
+	c.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("git ls-remote: %w", ctx.Err())
+		}
+		out := strings.TrimSpace(stderr.String())
+		lower := strings.ToLower(out)
+		for _, pattern := range gitAuthErrors {
+			if strings.Contains(lower, pattern) {
+				msg, _, _ := strings.Cut(out, "\n")
+				return fmt.Errorf("git ls-remote: %s: %w", msg, errRepoAuth)
+			}
+		}
+		if msg, _, _ := strings.Cut(out, "\n"); msg != "" {
+			return fmt.Errorf("git ls-remote: %s", msg)
+		}
+		return fmt.Errorf("git ls-remote: %w", err)
+	}
+	return nil
+}
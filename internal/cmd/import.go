@@ -0,0 +1,609 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newImportCmd() *cobra.Command {
+	var inPath string
+	var mode string
+	var tablesCSV string
+	var keySpecs []string
+	var includeMigrations bool
+	var batch int
+	var after afterMutation
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Load JSONL produced by export back into tables",
+		Long: `Load the {"table":...,"row":...} objects written by export back into the
+database. Files ending in .gz or .zst are decompressed; .zst needs an
+arc-db built with -tags zstd. Rows are committed in transactions of --batch
+rows; an error rolls back only the batch it occurred in, and earlier batches
+stay committed. --batch 0 imports the whole file in one transaction.
+
+--mode controls conflicts: insert fails on an existing key, upsert updates the
+existing row, replace deletes and re-inserts it, skip keeps the existing row
+and counts it as skipped. Rows for tables that do not exist in the target
+database are skipped and counted.
+
+Upsert and skip detect conflicts on the table's declared primary key, or on
+the columns given with --key table:col1,col2. With a key, upsert reports
+inserted and updated rows separately.
+
+schema_migrations rows are ignored unless --include-schema-migrations is
+given. With it, the table is created if needed and rows are added for
+versions the target has not recorded, whatever --mode says; recorded
+versions are kept. Versions added this way count as applied, so migrate up
+will not run them. To clone a database, run migrate up on the target first
+so its tables exist, then import.
+
+--vacuum-after and --analyze-after run VACUUM and ANALYZE once the import
+has finished, e.g. to refresh planner statistics after a large load.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch mode {
+			case "insert", "upsert", "replace", "skip":
+			default:
+				return fmt.Errorf("unknown mode %q (want insert, upsert, replace or skip)", mode)
+			}
+			if strings.TrimSpace(inPath) == "" {
+				return fmt.Errorf("--in is required")
+			}
+			if batch < 0 {
+				return fmt.Errorf("--batch must not be negative")
+			}
+			keys, err := parseColumnSpec(strings.Join(keySpecs, ";"))
+			if err != nil {
+				return fmt.Errorf("--key: %w", err)
+			}
+			if len(keys) > 0 && mode != "upsert" && mode != "skip" {
+				return fmt.Errorf("--key only applies to --mode upsert or skip")
+			}
+
+			in, closeIn, err := openInput(inPath)
+			if err != nil {
+				return err
+			}
+			defer closeIn()
+
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			if includeMigrations {
+				if err := ensureMigrationsTable(cmd.Context(), database); err != nil {
+					return err
+				}
+				// migrate verify --record may have added checksums to the dump.
+				if err := ensureChecksumColumn(database); err != nil {
+					return err
+				}
+			}
+
+			imp := newImporter(mode, parseTableList(tablesCSV))
+			imp.keys = keys
+			imp.migrations = includeMigrations
+			imp.batch = batch
+			if err := imp.run(database, in); err != nil {
+				return err
+			}
+			imp.report()
+			return after.run(cmd.Context(), database)
+		},
+	}
+
+	cmd.Flags().StringVar(&inPath, "in", "", "JSONL file to import")
+	cmd.Flags().StringVar(&mode, "mode", "insert", "Conflict handling: insert, upsert, replace or skip")
+	cmd.Flags().StringVar(&tablesCSV, "tables", "", "Only import these tables (comma-separated)")
+	cmd.Flags().IntVar(&batch, "batch", defaultImportBatch, "Rows per transaction (0 imports everything in one transaction)")
+	cmd.Flags().BoolVar(&includeMigrations, "include-schema-migrations", false, "Restore schema_migrations rows, keeping versions already recorded")
+	cmd.Flags().StringArrayVar(&keySpecs, "key", nil, "Conflict key for upsert/skip, e.g. repo_dependencies:repo_name,dependency_name (repeatable; default: primary key)")
+	after.addFlags(cmd)
+
+	return cmd
+}
+
+func openInput(path string) (io.Reader, func(), error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var zr io.ReadCloser
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		zr, err = gzip.NewReader(f)
+	case strings.HasSuffix(path, ".zst"):
+		zr, err = newZstdReader(f)
+	default:
+		return f, func() { f.Close() }, nil
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return zr, func() { zr.Close(); f.Close() }, nil
+}
+
+// defaultImportBatch is the number of rows import and seed commit at a time.
+const defaultImportBatch = 500
+
+// importer inserts exported rows, preparing one statement per table and
+// column set.
+type importer struct {
+	mode   string
+	batch  int
+	only   map[string]bool
+	stmts  map[string]*sql.Stmt
+	exists map[string]bool
+	cols   map[string][]string
+	// keys holds the --key conflict targets and pks the declared primary
+	// keys; keyStmts look a key up to tell updates from inserts.
+	keys     map[string][]string
+	pks      map[string][]string
+	keyStmts map[string]*sql.Stmt
+	// migrations restores schema_migrations rows, which are otherwise
+	// ignored and counted in ignoredMigrations.
+	migrations        bool
+	ignoredMigrations int
+
+	imported map[string]int
+	updated  map[string]int
+	unkeyed  map[string]int // upserted without a key, so not split out
+	skipped  map[string]int
+	missing  map[string]int
+}
+
+func newImporter(mode string, tables []string) *importer {
+	imp := &importer{
+		mode:     mode,
+		stmts:    map[string]*sql.Stmt{},
+		exists:   map[string]bool{},
+		cols:     map[string][]string{},
+		keys:     map[string][]string{},
+		pks:      map[string][]string{},
+		keyStmts: map[string]*sql.Stmt{},
+		imported: map[string]int{},
+		updated:  map[string]int{},
+		unkeyed:  map[string]int{},
+		skipped:  map[string]int{},
+		missing:  map[string]int{},
+	}
+	if len(tables) > 0 {
+		imp.only = map[string]bool{}
+		for _, t := range tables {
+			imp.only[t] = true
+		}
+	}
+	return imp
+}
+
+type exportedRow struct {
+	Table string                     `json:"table"`
+	Row   map[string]json.RawMessage `json:"row"`
+}
+
+func (imp *importer) run(database *sql.DB, in io.Reader) error {
+	bt := &batchTx{database: database, imp: imp, size: imp.batch}
+	defer bt.rollback()
+
+	r := bufio.NewReader(in)
+	for line := 1; ; line++ {
+		b, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(b)) > 0 {
+			tx, terr := bt.current()
+			if terr != nil {
+				return terr
+			}
+			if ierr := imp.importLine(tx, b); ierr != nil {
+				return bt.failed(line, ierr)
+			}
+			if cerr := bt.rowDone(); cerr != nil {
+				return fmt.Errorf("line %d: commit: %w", line, cerr)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return bt.commit()
+}
+
+// batchTx commits rows in transactions of size rows, or in one transaction
+// when size is 0. Statements the importer prepared belong to the
+// transaction, so they are closed at each commit and prepared again on
+// demand.
+type batchTx struct {
+	database  *sql.DB
+	imp       *importer
+	size      int
+	tx        *sql.Tx
+	rows      int
+	committed int
+}
+
+// current returns the open transaction, beginning one if needed.
+func (bt *batchTx) current() (*sql.Tx, error) {
+	if bt.tx == nil {
+		tx, err := bt.database.Begin()
+		if err != nil {
+			return nil, err
+		}
+		bt.tx = tx
+	}
+	return bt.tx, nil
+}
+
+// rowDone counts a row and commits the batch once it is full.
+func (bt *batchTx) rowDone() error {
+	bt.rows++
+	if bt.size > 0 && bt.rows >= bt.size {
+		return bt.commit()
+	}
+	return nil
+}
+
+func (bt *batchTx) commit() error {
+	if bt.tx == nil {
+		return nil
+	}
+	bt.imp.closeStmts()
+	err := bt.tx.Commit()
+	bt.tx = nil
+	if err != nil {
+		return err
+	}
+	bt.committed += bt.rows
+	bt.rows = 0
+	return nil
+}
+
+func (bt *batchTx) rollback() {
+	if bt.tx == nil {
+		return
+	}
+	bt.imp.closeStmts()
+	bt.tx.Rollback()
+	bt.tx = nil
+}
+
+// failed rolls back the current batch and describes err at line, noting
+// how many rows earlier batches already committed.
+func (bt *batchTx) failed(line int, err error) error {
+	bt.rollback()
+	if bt.committed > 0 {
+		return fmt.Errorf("line %d: %w (batch rolled back; %d earlier rows remain committed)", line, err, bt.committed)
+	}
+	return fmt.Errorf("line %d: %w", line, err)
+}
+
+func (imp *importer) importLine(tx *sql.Tx, b []byte) error {
+	var rec exportedRow
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return err
+	}
+	if rec.Table == "" || rec.Row == nil {
+		// Header objects and anything else without a row are not data.
+		return nil
+	}
+	if imp.only != nil && !imp.only[rec.Table] {
+		return nil
+	}
+	if rec.Table == migrationsTable && !imp.migrations {
+		imp.ignoredMigrations++
+		return nil
+	}
+	return imp.importRow(tx, rec.Table, rec.Row)
+}
+
+// importRow inserts one row into table, counting it as imported, skipped
+// (mode "skip" and the row already exists) or missing (no such table).
+func (imp *importer) importRow(tx *sql.Tx, table string, row map[string]json.RawMessage) error {
+	exists, ok := imp.exists[table]
+	if !ok {
+		var err error
+		if exists, err = tableExists(tx, table); err != nil {
+			return err
+		}
+		imp.exists[table] = exists
+		if exists {
+			if err := imp.loadTable(tx, table); err != nil {
+				return err
+			}
+		}
+	}
+	if !exists {
+		imp.missing[table]++
+		return nil
+	}
+
+	cols := make([]string, 0, len(row))
+	for c := range row {
+		if !containsString(imp.cols[table], c) {
+			return fmt.Errorf("unknown column %q in %s", c, table)
+		}
+		cols = append(cols, c)
+	}
+	sort.Strings(cols)
+
+	key := imp.conflictKey(table, cols)
+	st, err := imp.stmt(tx, table, cols, key)
+	if err != nil {
+		return err
+	}
+	args := make([]any, len(cols))
+	for i, c := range cols {
+		if args[i], err = importValue(row[c]); err != nil {
+			return fmt.Errorf("column %s: %w", c, err)
+		}
+	}
+	existed := false
+	mode := imp.modeFor(table)
+	if mode == "upsert" {
+		if len(key) == 0 {
+			imp.unkeyed[table]++
+		} else if existed, err = imp.keyExists(tx, table, key, row); err != nil {
+			return err
+		}
+	}
+	res, err := st.Exec(args...)
+	if err != nil {
+		return err
+	}
+	if existed {
+		imp.updated[table]++
+		return nil
+	}
+	if mode == "skip" {
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			imp.skipped[table]++
+			return nil
+		}
+	}
+	imp.imported[table]++
+	return nil
+}
+
+// loadTable reads table's columns and settles its conflict key, checking
+// that --key columns exist.
+func (imp *importer) loadTable(tx *sql.Tx, table string) error {
+	cols, pk, err := schemaColumns(tx, "main", table)
+	if err != nil {
+		return err
+	}
+	imp.cols[table] = cols
+	imp.pks[table] = pk
+	for _, c := range imp.keys[table] {
+		if !containsString(cols, c) {
+			return fmt.Errorf("--key: unknown column %q in %s (columns: %s)", c, table, strings.Join(cols, ", "))
+		}
+	}
+	return nil
+}
+
+// conflictKey is the --key for table, or else its declared primary key when
+// cols includes all of it. Rows without a key value, such as those relying
+// on an auto-assigned rowid, get no conflict target.
+func (imp *importer) conflictKey(table string, cols []string) []string {
+	if key, ok := imp.keys[table]; ok {
+		return key
+	}
+	for _, c := range imp.pks[table] {
+		if !containsString(cols, c) {
+			return nil
+		}
+	}
+	return imp.pks[table]
+}
+
+// keyExists reports whether a row with row's key values is already present.
+func (imp *importer) keyExists(tx *sql.Tx, table string, key []string, row map[string]json.RawMessage) (bool, error) {
+	st, ok := imp.keyStmts[table]
+	if !ok {
+		conds := make([]string, len(key))
+		for i, c := range key {
+			conds[i] = quoteIdent(c) + " = ?"
+		}
+		var err error
+		st, err = tx.Prepare(fmt.Sprintf("SELECT 1 FROM %s WHERE %s", quoteIdent(table), strings.Join(conds, " AND ")))
+		if err != nil {
+			return false, err
+		}
+		imp.keyStmts[table] = st
+	}
+
+	args := make([]any, len(key))
+	for i, c := range key {
+		raw, ok := row[c]
+		if !ok {
+			return false, fmt.Errorf("row has no value for key column %s", c)
+		}
+		var err error
+		if args[i], err = importValue(raw); err != nil {
+			return false, fmt.Errorf("column %s: %w", c, err)
+		}
+	}
+	var one int
+	err := st.QueryRow(args...).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// modeFor is the conflict mode used for table. Recorded migrations are
+// never rewritten, so schema_migrations always keeps existing rows.
+func (imp *importer) modeFor(table string) string {
+	if table == migrationsTable {
+		return "skip"
+	}
+	return imp.mode
+}
+
+// closeStmts closes the prepared statements, which belong to the
+// transaction they were prepared on.
+func (imp *importer) closeStmts() {
+	for key, st := range imp.stmts {
+		st.Close()
+		delete(imp.stmts, key)
+	}
+	for table, st := range imp.keyStmts {
+		st.Close()
+		delete(imp.keyStmts, table)
+	}
+}
+
+func (imp *importer) stmt(tx *sql.Tx, table string, cols, target []string) (*sql.Stmt, error) {
+	key := table + "\x00" + strings.Join(cols, "\x00")
+	if st, ok := imp.stmts[key]; ok {
+		return st, nil
+	}
+
+	quoted := make([]string, len(cols))
+	marks := make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = quoteIdent(c)
+		marks[i] = "?"
+	}
+
+	mode := imp.modeFor(table)
+	verb := "INSERT"
+	if mode == "replace" {
+		verb = "INSERT OR REPLACE"
+	}
+	q := fmt.Sprintf("%s INTO %s (%s) VALUES (%s)", verb, quoteIdent(table), strings.Join(quoted, ", "), strings.Join(marks, ", "))
+	conflict := func(key []string) string {
+		if len(key) == 0 {
+			return " ON CONFLICT"
+		}
+		return " ON CONFLICT(" + strings.Join(quoteEach(key), ", ") + ")"
+	}
+	switch mode {
+	case "upsert":
+		var sets []string
+		for _, c := range cols {
+			if !containsString(target, c) {
+				sets = append(sets, fmt.Sprintf("%s = excluded.%s", quoteIdent(c), quoteIdent(c)))
+			}
+		}
+		if len(sets) == 0 {
+			q += conflict(target) + " DO NOTHING"
+		} else {
+			q += conflict(target) + " DO UPDATE SET " + strings.Join(sets, ", ")
+		}
+	case "skip":
+		// Without --key any constraint conflict skips the row.
+		q += conflict(imp.keys[table]) + " DO NOTHING"
+	}
+
+	st, err := tx.Prepare(q)
+	if err != nil {
+		return nil, err
+	}
+	imp.stmts[key] = st
+	return st, nil
+}
+
+func (imp *importer) report() {
+	seen := map[string]bool{}
+	var tables []string
+	total, skipped, into := 0, 0, 0
+	for _, counts := range []map[string]int{imp.imported, imp.updated, imp.skipped} {
+		for t := range counts {
+			if !seen[t] {
+				seen[t] = true
+				tables = append(tables, t)
+			}
+		}
+	}
+	sort.Strings(tables)
+	for _, t := range tables {
+		if n := imp.imported[t] + imp.updated[t]; n > 0 {
+			total += n
+			into++
+		}
+		skipped += imp.skipped[t]
+		switch {
+		case imp.modeFor(t) == "upsert" && imp.unkeyed[t] == 0:
+			fmt.Printf("  %-20s %d (%d inserted, %d updated)\n", t+":", imp.imported[t]+imp.updated[t], imp.imported[t], imp.updated[t])
+		case imp.modeFor(t) == "skip":
+			fmt.Printf("  %-20s %d inserted, %d skipped\n", t+":", imp.imported[t], imp.skipped[t])
+		default:
+			fmt.Printf("  %-20s %d\n", t+":", imp.imported[t])
+		}
+	}
+	if skipped > 0 {
+		fmt.Printf("Imported %d rows into %d tables, skipped %d existing rows\n", total, into, skipped)
+	} else {
+		fmt.Printf("Imported %d rows into %d tables\n", total, into)
+	}
+
+	if len(imp.missing) > 0 {
+		skipped := 0
+		names := make([]string, 0, len(imp.missing))
+		for t, n := range imp.missing {
+			names = append(names, t)
+			skipped += n
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, "warning: skipped %d rows for missing tables: %s\n", skipped, strings.Join(names, ", "))
+	}
+	if imp.ignoredMigrations > 0 {
+		fmt.Fprintf(os.Stderr, "warning: ignored %d schema_migrations rows; pass --include-schema-migrations to restore them\n", imp.ignoredMigrations)
+	}
+}
+
+// importValue converts an exported JSON value back into a SQLite argument,
+// decoding the base64 and hex BLOB markers written by export.
+func importValue(raw json.RawMessage) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	switch v := v.(type) {
+	case nil, string:
+		return v, nil
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		return v.Float64()
+	case map[string]any:
+		if s, ok := v[blobMarker].(string); ok && len(v) == 1 {
+			return base64.StdEncoding.DecodeString(s)
+		}
+		if s, ok := v[hexMarker].(string); ok && len(v) == 1 {
+			return hex.DecodeString(s)
+		}
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestBlobRoundTrip(t *testing.T) {
+	values := [][]byte{
+		{0x00, 0xff, 0xfe, 0x80},
+		[]byte("valid utf-8 stored as a blob"),
+		{0x1f, 0x8b, 0x08, 0x00, 0x00},
+		[]byte("nul\x00inside"),
+		{},
+	}
+	for _, mode := range []string{blobAuto, blobBase64, blobHex} {
+		t.Run(mode, func(t *testing.T) {
+			src := newTestDB(t, `CREATE TABLE t (id INTEGER PRIMARY KEY, b BLOB, c TEXT)`)
+			for i, v := range values {
+				// Blobs keep their storage class in c despite its TEXT
+				// affinity.
+				if _, err := src.Exec(`INSERT INTO t VALUES (?, ?, ?)`, i, v, v); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var buf bytes.Buffer
+			w := bufio.NewWriter(&buf)
+			opts := testJSONLOptions
+			opts.blob = mode
+			query := exportQuery{order: []string{"rowid"}}
+			if _, err := writeExport(src, w, outputOptions{}, newExportFormat("jsonl", query, opts), []string{"t"}, query); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			dst := newTestDB(t, `CREATE TABLE t (id INTEGER PRIMARY KEY, b BLOB, c TEXT)`)
+			imp := newImporter("insert", nil)
+			if err := imp.run(dst, &buf); err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range values {
+				for _, col := range []string{"b", "c"} {
+					var got []byte
+					var typ string
+					if err := dst.QueryRow(`SELECT `+col+`, typeof(`+col+`) FROM t WHERE id = ?`, i).Scan(&got, &typ); err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(got, want) {
+						t.Errorf("row %d %s = %q, want %q", i, col, got, want)
+					}
+					// auto writes valid UTF-8 outside BLOB columns as a
+					// string, so only the bytes survive there.
+					if typ != "blob" && (col == "b" || mode != blobAuto) {
+						t.Errorf("row %d %s came back as %s, want blob", i, col, typ)
+					}
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkImport compares committing every row, as import did before
+// --batch, with the default batch and a single transaction.
+func BenchmarkImport(b *testing.B) {
+	data := []byte(exportString(b, newBenchmarkDB(b), "jsonl", "t"))
+
+	for _, batch := range []int{1, defaultImportBatch, 0} {
+		b.Run(fmt.Sprintf("batch %d", batch), func(b *testing.B) {
+			dir := b.TempDir()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				database, err := openDB(filepath.Join(dir, fmt.Sprintf("import-%d.db", i)))
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := database.Exec(`CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT, score REAL, created_at INTEGER)`); err != nil {
+					b.Fatal(err)
+				}
+				imp := newImporter("insert", nil)
+				imp.batch = batch
+				b.StartTimer()
+
+				if err := imp.run(database, bytes.NewReader(data)); err != nil {
+					b.Fatal(err)
+				}
+				imp.closeStmts()
+				database.Close()
+			}
+			b.ReportMetric(float64(benchmarkRows*b.N)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+}
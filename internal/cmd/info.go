@@ -0,0 +1,172 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// dbInfo is the data reported by the info command.
+type dbInfo struct {
+	DBPath        string            `json:"db_path"`
+	SQLiteVersion string            `json:"sqlite_version"`
+	FileSizeBytes int64             `json:"file_size_bytes"`
+	Tables        map[string]int    `json:"tables"`
+	CountErrors   map[string]string `json:"count_errors,omitempty"`
+	Sizes         []tableSize       `json:"sizes,omitempty"`
+
+	tableNames []string
+}
+
+// collectInfo gathers the path, version, file size and per-table row counts.
+// Tables whose count cannot be read are left out of Tables and their error
+// is recorded in CountErrors instead.
+func collectInfo(database *sql.DB, path string) (*dbInfo, error) {
+	info := &dbInfo{DBPath: path, Tables: map[string]int{}}
+
+	var ver string
+	if err := database.QueryRow("SELECT sqlite_version();").Scan(&ver); err == nil {
+		info.SQLiteVersion = ver
+	}
+	if st, err := os.Stat(path); err == nil {
+		info.FileSizeBytes = st.Size()
+	}
+
+	tables, err := listTables(database)
+	if err != nil {
+		return nil, err
+	}
+	info.tableNames = tables
+
+	for _, tbl := range tables {
+		var cnt int
+		err := database.QueryRow(fmt.Sprintf("SELECT count(*) FROM %s", quoteIdent(tbl))).Scan(&cnt)
+		if err != nil {
+			if info.CountErrors == nil {
+				info.CountErrors = map[string]string{}
+			}
+			info.CountErrors[tbl] = err.Error()
+			continue
+		}
+		info.Tables[tbl] = cnt
+	}
+	return info, nil
+}
+
+// writePrometheus writes info in the Prometheus text exposition format. The
+// metric names are documented in the info command's help and must stay
+// stable for existing scrapers.
+func writePrometheus(out io.Writer, info *dbInfo) error {
+	w := bufio.NewWriter(out)
+	metric := func(name, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+
+	metric("arc_db_table_rows", "Number of rows in each table.")
+	for _, tbl := range info.tableNames {
+		if cnt, ok := info.Tables[tbl]; ok {
+			fmt.Fprintf(w, "arc_db_table_rows{table=\"%s\"} %d\n", promLabel(tbl), cnt)
+		}
+	}
+	if len(info.CountErrors) > 0 {
+		metric("arc_db_table_count_errors", "1 for each table whose rows could not be counted.")
+		for _, tbl := range info.tableNames {
+			if _, ok := info.CountErrors[tbl]; ok {
+				fmt.Fprintf(w, "arc_db_table_count_errors{table=\"%s\"} 1\n", promLabel(tbl))
+			}
+		}
+	}
+	metric("arc_db_file_bytes", "Size of the database file in bytes.")
+	fmt.Fprintf(w, "arc_db_file_bytes %d\n", info.FileSizeBytes)
+	metric("arc_db_sqlite_version_info", "SQLite library version, as a label.")
+	fmt.Fprintf(w, "arc_db_sqlite_version_info{version=\"%s\"} 1\n", promLabel(info.SQLiteVersion))
+
+	if len(info.Sizes) > 0 {
+		metric("arc_db_table_data_bytes", "Bytes of table data pages, from dbstat.")
+		for _, ts := range info.Sizes {
+			fmt.Fprintf(w, "arc_db_table_data_bytes{table=\"%s\"} %d\n", promLabel(ts.Table), ts.DataBytes)
+		}
+		metric("arc_db_table_index_bytes", "Bytes of index pages per table, from dbstat.")
+		for _, ts := range info.Sizes {
+			fmt.Fprintf(w, "arc_db_table_index_bytes{table=\"%s\"} %d\n", promLabel(ts.Table), ts.IndexBytes)
+		}
+	}
+	return w.Flush()
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promLabel escapes a label value for the text exposition format.
+func promLabel(v string) string {
+	return promLabelEscaper.Replace(v)
+}
+
+// tableSize is the on-disk footprint of one table and its indexes.
+type tableSize struct {
+	Table      string `json:"table"`
+	DataBytes  int64  `json:"data_bytes"`
+	IndexBytes int64  `json:"index_bytes"`
+}
+
+// errNoDBStat is returned by tableSizes when SQLite was built without the
+// dbstat virtual table.
+var errNoDBStat = errors.New("dbstat virtual table not available")
+
+// tableSizes sums dbstat page sizes per table, splitting index pages out
+// from table data, and sorts the result largest first.
+func tableSizes(database *sql.DB) ([]tableSize, error) {
+	rows, err := database.Query(`SELECT m.tbl_name, m.type, sum(s.pgsize)
+		FROM dbstat s JOIN sqlite_master m ON m.name = s.name
+		WHERE m.tbl_name NOT LIKE 'sqlite_%'
+		GROUP BY m.tbl_name, m.type`)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table: dbstat") {
+			return nil, errNoDBStat
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	byTable := map[string]*tableSize{}
+	for rows.Next() {
+		var name, typ string
+		var size int64
+		if err := rows.Scan(&name, &typ, &size); err != nil {
+			return nil, err
+		}
+		ts, ok := byTable[name]
+		if !ok {
+			ts = &tableSize{Table: name}
+			byTable[name] = ts
+		}
+		if typ == "index" {
+			ts.IndexBytes += size
+		} else {
+			ts.DataBytes += size
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]tableSize, 0, len(byTable))
+	for _, ts := range byTable {
+		out = append(out, *ts)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].DataBytes+out[i].IndexBytes, out[j].DataBytes+out[j].IndexBytes
+		if a != b {
+			return a > b
+		}
+		return out[i].Table < out[j].Table
+	})
+	return out, nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// verboseFlag holds the persistent --verbose flag.
+var verboseFlag bool
+
+// statementLogger is told about each statement the migrator and the
+// maintenance commands run. db.Open has no logging hook, so those commands
+// report their statements through execLogged and startStatement instead.
+type statementLogger interface {
+	Statement(query string, elapsed time.Duration, err error)
+}
+
+// stmtLogger is nil, logging nothing, unless --verbose installs stderrLogger.
+var stmtLogger statementLogger
+
+// stderrLogger writes one line per statement to stderr.
+type stderrLogger struct{}
+
+func (stderrLogger) Statement(query string, elapsed time.Duration, err error) {
+	query = strings.Join(strings.Fields(query), " ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sql: %s (%s): %v\n", query, elapsed.Round(time.Microsecond), err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "sql: %s (%s)\n", query, elapsed.Round(time.Microsecond))
+}
+
+// startStatement opens a trace span for query. Call the returned func when
+// the statement finishes to close the span and report query with its
+// duration to the installed logger.
+func startStatement(ctx context.Context, query string) func(error) {
+	start := time.Now()
+	_, end := startSpan(ctx, "sql", summarizeStatement(query))
+	return func(err error) {
+		end(err)
+		if stmtLogger != nil {
+			stmtLogger.Statement(query, time.Since(start), err)
+		}
+	}
+}
+
+// execLogged runs query on ex and logs it with its duration.
+func execLogged(ctx context.Context, ex execer, query string, args ...any) (sql.Result, error) {
+	done := startStatement(ctx, query)
+	res, err := ex.ExecContext(ctx, query, args...)
+	done(err)
+	return res, err
+}
@@ -0,0 +1,492 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newCheckpointCmd() *cobra.Command {
+	var mode string
+
+	cmd := &cobra.Command{
+		Use:   "checkpoint",
+		Short: "Checkpoint the write-ahead log",
+		Long: `Run PRAGMA wal_checkpoint to copy WAL frames into the database file.
+The default truncate mode also shrinks the -wal file to zero bytes.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mode = strings.ToUpper(mode)
+			switch mode {
+			case "PASSIVE", "FULL", "RESTART", "TRUNCATE":
+			default:
+				return fmt.Errorf("unknown mode %q (want passive, full, restart or truncate)", strings.ToLower(mode))
+			}
+
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			res, err := walCheckpoint(cmd.Context(), database, mode)
+			if err != nil {
+				return err
+			}
+			if res.notWAL {
+				fmt.Fprintln(os.Stderr, "warning: database is not in WAL mode; checkpoint is a no-op")
+				return nil
+			}
+			fmt.Printf("Checkpointed %d of %d WAL frames (%s)\n", res.checkpointed, res.logFrames, strings.ToLower(mode))
+			if res.busy {
+				return fmt.Errorf("checkpoint did not complete: database is busy")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&mode, "mode", "truncate", "Checkpoint mode: passive, full, restart or truncate")
+
+	return cmd
+}
+
+type checkpointResult struct {
+	notWAL       bool
+	busy         bool
+	logFrames    int
+	checkpointed int
+}
+
+// walCheckpoint runs PRAGMA wal_checkpoint(mode). notWAL is set when the
+// database uses a rollback journal and there was nothing to do.
+func walCheckpoint(ctx context.Context, database *sql.DB, mode string) (checkpointResult, error) {
+	var res checkpointResult
+	var journal string
+	if err := database.QueryRow("PRAGMA journal_mode").Scan(&journal); err != nil {
+		return res, err
+	}
+	if !strings.EqualFold(journal, "wal") {
+		res.notWAL = true
+		return res, nil
+	}
+
+	var busy int
+	stmt := "PRAGMA wal_checkpoint(" + mode + ")"
+	done := startStatement(ctx, stmt)
+	err := database.QueryRowContext(ctx, stmt).Scan(&busy, &res.logFrames, &res.checkpointed)
+	done(err)
+	if err != nil {
+		return res, err
+	}
+	res.busy = busy != 0
+	return res, nil
+}
+
+// errLockTimeout is returned by waitForWriteLock when the write lock stayed
+// busy for the whole timeout.
+var errLockTimeout = errors.New("timed out waiting for the write lock")
+
+// waitForWriteLock polls for the database write lock on conn, calling tick
+// (if set) after each busy attempt, until it is free or timeout passes. It
+// leaves conn's busy_timeout set to the time remaining so the statement run
+// next on conn gives up at the same deadline.
+func waitForWriteLock(ctx context.Context, conn *sql.Conn, timeout time.Duration, tick func()) error {
+	deadline := time.Now().Add(timeout)
+	if _, err := conn.ExecContext(ctx, "PRAGMA busy_timeout = 0"); err != nil {
+		return err
+	}
+	for {
+		_, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+		if err == nil {
+			if _, err := conn.ExecContext(ctx, "ROLLBACK"); err != nil {
+				return err
+			}
+			break
+		}
+		if !isBusy(err) {
+			return err
+		}
+		if !time.Now().Before(deadline) {
+			return errLockTimeout
+		}
+		if tick != nil {
+			tick()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	remaining := time.Until(deadline).Milliseconds()
+	if remaining < 1 {
+		remaining = 1
+	}
+	_, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA busy_timeout = %d", remaining))
+	return err
+}
+
+func isBusy(err error) bool {
+	return errors.Is(err, errLockTimeout) || strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY")
+}
+
+// lockTimeoutError describes a lock timeout on path, naming the other
+// processes that have the database open. SQLite does not record who holds a
+// lock, so open file handles are the best available hint. Errors that are
+// not lock timeouts are returned unchanged.
+func lockTimeoutError(path string, timeout time.Duration, err error) error {
+	if !isBusy(err) {
+		return err
+	}
+	holders := openFileHolders(path)
+	if len(holders) == 0 {
+		return fmt.Errorf("%s is locked: no write lock within %s", path, timeout)
+	}
+	return fmt.Errorf("%s is locked: no write lock within %s; open by %s", path, timeout, strings.Join(holders, ", "))
+}
+
+// openFileHolders lists the other processes with path, or its -wal or
+// journal sidecars, open. It reads /proc and returns nothing where that is
+// unavailable.
+func openFileHolders(path string) []string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	targets := map[string]bool{abs: true, abs + "-wal": true, abs + "-journal": true}
+
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, p := range procs {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		fdDir := filepath.Join("/proc", p.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !targets[link] {
+				continue
+			}
+			name := "?"
+			if b, err := os.ReadFile(filepath.Join("/proc", p.Name(), "comm")); err == nil {
+				name = strings.TrimSpace(string(b))
+			}
+			out = append(out, fmt.Sprintf("pid %d (%s)", pid, name))
+			break
+		}
+	}
+	return out
+}
+
+func newAnalyzeCmd() *cobra.Command {
+	var table string
+
+	cmd := &cobra.Command{
+		Use:   "analyze",
+		Short: "Refresh query planner statistics",
+		Long:  `Run ANALYZE (on one table with --table) followed by PRAGMA optimize.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			if table != "" {
+				if ok, err := checkTable(database, table); err != nil {
+					return err
+				} else if !ok {
+					return unknownTableError(database, table)
+				}
+			}
+
+			elapsed, err := analyze(cmd.Context(), database, table)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("ANALYZE completed in %dms\n", elapsed.Milliseconds())
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&table, "table", "", "Analyze only this table")
+
+	return cmd
+}
+
+// afterMutation holds the --vacuum-after and --analyze-after flags shared by
+// the commands that delete or load data.
+type afterMutation struct {
+	vacuum  bool
+	analyze bool
+}
+
+func (a *afterMutation) addFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&a.vacuum, "vacuum-after", false, "Run VACUUM once the changes are committed")
+	cmd.Flags().BoolVar(&a.analyze, "analyze-after", false, "Run ANALYZE and PRAGMA optimize once the changes are committed")
+}
+
+// run performs the requested maintenance. Call it only after every
+// transaction of the mutation has been committed or rolled back: VACUUM
+// cannot run inside one.
+func (a afterMutation) run(ctx context.Context, database *sql.DB) error {
+	if a.vacuum {
+		start := time.Now()
+		if _, err := execLogged(ctx, database, "VACUUM"); err != nil {
+			return fmt.Errorf("vacuum: %w", err)
+		}
+		fmt.Printf("VACUUM completed in %dms\n", time.Since(start).Milliseconds())
+	}
+	if a.analyze {
+		elapsed, err := analyze(ctx, database, "")
+		if err != nil {
+			return fmt.Errorf("analyze: %w", err)
+		}
+		fmt.Printf("ANALYZE completed in %dms\n", elapsed.Milliseconds())
+	}
+	return nil
+}
+
+func analyze(ctx context.Context, database *sql.DB, table string) (time.Duration, error) {
+	start := time.Now()
+	stmt := "ANALYZE"
+	if table != "" {
+		stmt += " " + quoteIdent(table)
+	}
+	if _, err := execLogged(ctx, database, stmt); err != nil {
+		return 0, err
+	}
+	if _, err := execLogged(ctx, database, "PRAGMA optimize"); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// maintenanceSteps selects what maintenance runs.
+type maintenanceSteps struct {
+	vacuum, analyze, checkpoint bool
+}
+
+func newMaintenanceCmd() *cobra.Command {
+	var steps maintenanceSteps
+	var glob string
+	var concurrency int
+
+	cmd := &cobra.Command{
+		Use:   "maintenance",
+		Short: "Run vacuum, analyze and checkpoint in one go",
+		Long: `Run VACUUM, ANALYZE with PRAGMA optimize, and a truncating WAL checkpoint,
+in that order. Disable individual steps with --vacuum=false and so on.
+
+With --glob, every database file matching the pattern is maintained instead
+of --db, --concurrency at a time. A failing file does not stop the others;
+each file's results are printed as it finishes, and the command fails at the
+end if any file did.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if concurrency < 1 {
+				return fmt.Errorf("--concurrency must be at least 1")
+			}
+			if glob == "" {
+				if concurrency > 1 {
+					return fmt.Errorf("--concurrency needs --glob")
+				}
+				return runMaintenance(cmd.Context(), dbPath(), steps, os.Stdout)
+			}
+
+			paths, err := filepath.Glob(glob)
+			if err != nil {
+				return fmt.Errorf("--glob: %w", err)
+			}
+			if len(paths) == 0 {
+				return fmt.Errorf("no files match %s", glob)
+			}
+			sort.Strings(paths)
+
+			var mu sync.Mutex
+			failed := 0
+			jobs := make(chan string)
+			var wg sync.WaitGroup
+			for w := 0; w < concurrency; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for path := range jobs {
+						// Buffered so that parallel files do not interleave.
+						var out bytes.Buffer
+						err := runMaintenance(cmd.Context(), path, steps, &out)
+						mu.Lock()
+						fmt.Printf("%s:\n", path)
+						for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+							if line != "" {
+								fmt.Printf("  %s\n", line)
+							}
+						}
+						if err != nil {
+							failed++
+							fmt.Printf("  failed: %v\n", err)
+						}
+						mu.Unlock()
+					}
+				}()
+			}
+			for _, p := range paths {
+				jobs <- p
+			}
+			close(jobs)
+			wg.Wait()
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d database(s) failed maintenance", failed, len(paths))
+			}
+			fmt.Printf("Maintained %d database(s)\n", len(paths))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&steps.vacuum, "vacuum", true, "Run VACUUM")
+	cmd.Flags().BoolVar(&steps.analyze, "analyze", true, "Run ANALYZE and PRAGMA optimize")
+	cmd.Flags().BoolVar(&steps.checkpoint, "checkpoint", true, "Run a truncating WAL checkpoint")
+	cmd.Flags().StringVar(&glob, "glob", "", "Maintain every database file matching this pattern, e.g. '/data/*.db'")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 1, "Maintain up to this many --glob files at once")
+
+	return cmd
+}
+
+// runMaintenance runs steps on the database at path, reporting each to w.
+func runMaintenance(ctx context.Context, path string, steps maintenanceSteps, w io.Writer) error {
+	database, err := openDB(path)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if steps.vacuum {
+		start := time.Now()
+		if _, err := execLogged(ctx, database, "VACUUM"); err != nil {
+			return fmt.Errorf("vacuum: %w", err)
+		}
+		fmt.Fprintf(w, "vacuum:     %dms\n", time.Since(start).Milliseconds())
+	}
+	if steps.analyze {
+		elapsed, err := analyze(ctx, database, "")
+		if err != nil {
+			return fmt.Errorf("analyze: %w", err)
+		}
+		fmt.Fprintf(w, "analyze:    %dms\n", elapsed.Milliseconds())
+	}
+	if steps.checkpoint {
+		res, err := walCheckpoint(ctx, database, "TRUNCATE")
+		if err != nil {
+			return fmt.Errorf("checkpoint: %w", err)
+		}
+		switch {
+		case res.notWAL:
+			fmt.Fprintln(w, "checkpoint: skipped (not in WAL mode)")
+		case res.busy:
+			return fmt.Errorf("checkpoint: database is busy")
+		default:
+			fmt.Fprintf(w, "checkpoint: %d frames\n", res.checkpointed)
+		}
+	}
+	return nil
+}
+
+func newReindexCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "reindex [table|index]",
+		Short: "Rebuild indexes",
+		Long:  `Run REINDEX for the whole database, or for one table's indexes or a single index.`,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			name := ""
+			if len(args) == 1 {
+				name = args[0]
+			}
+			indexes, err := reindexTargets(database, name)
+			if err != nil {
+				return err
+			}
+
+			start := time.Now()
+			stmt := "REINDEX"
+			if name != "" {
+				stmt += " " + quoteIdent(name)
+			}
+			if _, err := execLogged(cmd.Context(), database, stmt); err != nil {
+				return err
+			}
+			for _, idx := range indexes {
+				fmt.Printf("  rebuilt %s\n", idx)
+			}
+			fmt.Printf("Rebuilt %d index(es) in %dms\n", len(indexes), time.Since(start).Milliseconds())
+			return nil
+		},
+	}
+}
+
+// reindexTargets lists the indexes REINDEX name will rebuild: every index
+// when name is empty, the table's indexes when name is a table, or name
+// itself when it is an index.
+func reindexTargets(database *sql.DB, name string) ([]string, error) {
+	query := `SELECT name FROM sqlite_master WHERE type='index' ORDER BY name`
+	var args []any
+	if name != "" {
+		var typ string
+		err := database.QueryRow(`SELECT type FROM sqlite_master WHERE name = ? AND type IN ('table', 'index')`, name).Scan(&typ)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no such table or index: %s", name)
+		} else if err != nil {
+			return nil, err
+		}
+		query = `SELECT name FROM sqlite_master WHERE type='index' AND tbl_name = ? ORDER BY name`
+		if typ == "index" {
+			query = `SELECT name FROM sqlite_master WHERE type='index' AND name = ?`
+		}
+		args = []any{name}
+	}
+
+	rows, err := database.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var idx string
+		if err := rows.Scan(&idx); err != nil {
+			return nil, err
+		}
+		out = append(out, idx)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,717 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yourorg/arc-sdk/db/migrations"
+)
+
+// Exit codes of migrate check.
+const (
+	exitMigrationsPending = 3
+	exitMigrationsUnknown = 4
+)
+
+func newMigrateCheckCmd() *cobra.Command {
+	var dir string
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Exit non-zero if the database and the migrations disagree",
+		Long: `Compare the applied migrations with the embedded ones (or those in
+--migrations-dir), for use as a deploy gate. Exit codes:
+
+  0  every migration is applied and none is unknown
+  1  the check itself failed, e.g. the database could not be opened
+  3  there are pending migrations
+  4  the database has applied migrations that are not known, e.g. after a
+     downgrade; this wins over 3 when both apply`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			avail, _, err := loadMigrations(dir)
+			if err != nil {
+				return err
+			}
+			applied, err := migrations.Applied(database)
+			if err != nil {
+				return err
+			}
+
+			known := map[int]bool{}
+			for _, m := range avail {
+				known[m.Version] = true
+			}
+			var unknown []int
+			for v := range applied {
+				if !known[v] {
+					unknown = append(unknown, v)
+				}
+			}
+			sort.Ints(unknown)
+			pending := pendingMigrations(avail, applied)
+
+			for _, v := range unknown {
+				fmt.Printf("  unknown %03d %s\n", v, applied[v])
+			}
+			for _, m := range pending {
+				fmt.Printf("  pending %03d %s\n", m.Version, m.Name)
+			}
+			switch {
+			case len(unknown) > 0:
+				return &exitError{code: exitMigrationsUnknown, err: fmt.Errorf("%d applied migration(s) not known to this binary", len(unknown))}
+			case len(pending) > 0:
+				return &exitError{code: exitMigrationsPending, err: fmt.Errorf("%d pending migration(s)", len(pending))}
+			}
+			fmt.Println("Migrations are up to date.")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "migrations-dir", "", "Check against the migrations in this directory instead of the embedded ones")
+
+	return cmd
+}
+
+func newMigrateToCmd() *cobra.Command {
+	var dir string
+	var lockTimeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "to <version>",
+		Short: "Migrate up or down to a specific version",
+		Long: `Apply or revert migrations until the given version is the latest applied.
+
+Steps run the up/down sources found in --dir, each inside its own
+transaction. Migrating to the latest embedded version falls back to the
+arc-sdk migrator, with a warning, when --dir lacks an up script for a pending
+migration; it then applies them in one call without per-migration
+transactions or checksums. Version 0 reverts every applied migration.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			end := traceCommand(cmd)
+			defer func() { end(err) }()
+
+			target, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid version %q", args[0])
+			}
+
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			unlock, err := acquireMigrationLock(cmd.Context(), database, lockTimeout)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+
+			avail, err := migrations.Embedded()
+			if err != nil {
+				return err
+			}
+			applied, err := migrations.Applied(database)
+			if err != nil {
+				return err
+			}
+
+			steps, up, err := planMigrateTo(avail, applied, target)
+			if err != nil {
+				return err
+			}
+			if len(steps) == 0 {
+				fmt.Printf("Already at version %03d.\n", target)
+				return nil
+			}
+
+			if up {
+				fmt.Printf("Applying %d migration(s) to reach %03d:\n", len(steps), target)
+			} else {
+				fmt.Printf("Reverting %d migration(s) to reach %03d:\n", len(steps), target)
+			}
+			for _, m := range steps {
+				fmt.Printf("  %03d %s\n", m.Version, m.Name)
+			}
+
+			if up && target == avail[len(avail)-1].Version && embeddedSources(dir, steps) == nil {
+				fmt.Fprintln(os.Stderr, embeddedMigratorWarning)
+				if err := migrations.RunMigrations(database); err != nil {
+					return err
+				}
+				fmt.Printf("Migrated to %03d.\n", target)
+				return nil
+			}
+
+			sources, err := readMigrationSources(dir)
+			if err != nil {
+				return fmt.Errorf("read migration sources: %w", err)
+			}
+			for _, m := range steps {
+				src := sources[m.Version]
+				if up && src.Up == "" {
+					return fmt.Errorf("no up script for %03d_%s in %s", m.Version, m.Name, dir)
+				}
+				if !up && src.Down == "" {
+					return fmt.Errorf("no down script for %03d_%s in %s", m.Version, m.Name, dir)
+				}
+			}
+			for _, m := range steps {
+				if up {
+					err = applyMigration(cmd.Context(), database, sources[m.Version])
+				} else {
+					err = revertMigration(cmd.Context(), database, sources[m.Version])
+				}
+				if err != nil {
+					return err
+				}
+			}
+			fmt.Printf("Migrated to %03d.\n", target)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "dir", defaultMigrationsDir, "Directory containing up/down migration sources")
+	cmd.Flags().DurationVar(&lockTimeout, "lock-timeout", 30*time.Second, "How long to wait for another migration run to finish (0 fails immediately)")
+
+	return cmd
+}
+
+func newMigrateRedoCmd() *cobra.Command {
+	var dir string
+	var steps int
+	var lockTimeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "redo",
+		Short: "Revert and re-apply the latest migrations",
+		Long: `Run the down script of the highest applied migration and then its up
+script again, using the sources in --dir. With --steps N the top N migrations
+are reverted newest first and re-applied oldest first. Everything runs in one
+transaction unless a script opts out with ` + noTransactionDirective + `.
+
+This is meant for iterating on a migration while writing it.`,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			end := traceCommand(cmd)
+			defer func() { end(err) }()
+
+			if steps < 1 {
+				return fmt.Errorf("--steps must be at least 1")
+			}
+
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			unlock, err := acquireMigrationLock(cmd.Context(), database, lockTimeout)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+
+			applied, err := migrations.Applied(database)
+			if err != nil {
+				return err
+			}
+			if steps > len(applied) {
+				return fmt.Errorf("--steps %d exceeds the %d applied migration(s)", steps, len(applied))
+			}
+			versions := make([]int, 0, len(applied))
+			for v := range applied {
+				versions = append(versions, v)
+			}
+			sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+			sources, err := readMigrationSources(dir)
+			if err != nil {
+				return fmt.Errorf("read migration sources: %w", err)
+			}
+			srcs := make([]migrationSource, 0, steps)
+			for _, v := range versions[:steps] {
+				src := sources[v]
+				if src.Down == "" {
+					return fmt.Errorf("no down script for %03d_%s in %s", v, applied[v], dir)
+				}
+				if src.Up == "" {
+					return fmt.Errorf("no up script for %03d_%s in %s", v, applied[v], dir)
+				}
+				srcs = append(srcs, src)
+			}
+
+			if err := redoMigrations(cmd.Context(), database, srcs); err != nil {
+				return err
+			}
+			for i := len(srcs) - 1; i >= 0; i-- {
+				fmt.Printf("  redid %03d %s\n", srcs[i].Version, srcs[i].Name)
+			}
+			fmt.Printf("Redid %d migration(s).\n", len(srcs))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "dir", defaultMigrationsDir, "Directory containing up/down migration sources")
+	cmd.Flags().IntVar(&steps, "steps", 1, "Number of migrations to redo, counting down from the latest")
+	cmd.Flags().DurationVar(&lockTimeout, "lock-timeout", 30*time.Second, "How long to wait for another migration run to finish (0 fails immediately)")
+
+	return cmd
+}
+
+// defaultMigrationsDir mirrors the layout of the embedded migrations in
+// arc-sdk (db/migrations/sql).
+const defaultMigrationsDir = "db/migrations/sql"
+
+var (
+	migrationNameRe = regexp.MustCompile(`^[a-z0-9_]+$`)
+	migrationFileRe = regexp.MustCompile(`^(\d+)_(.+?)(?:\.(up|down))?\.sql$`)
+)
+
+func newMigrateCreateCmd() *cobra.Command {
+	var dir string
+
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Scaffold up/down files for a new migration",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !migrationNameRe.MatchString(name) {
+				return fmt.Errorf("invalid migration name %q: use lowercase letters, digits and underscores", name)
+			}
+
+			avail, err := migrations.Embedded()
+			if err != nil {
+				return err
+			}
+			next, err := nextMigrationVersion(avail, dir)
+			if err != nil {
+				return err
+			}
+
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+			created := time.Now().Format("2006-01-02")
+			for _, kind := range []string{"up", "down"} {
+				file := fmt.Sprintf("%03d_%s.%s.sql", next, name, kind)
+				path := filepath.Join(dir, file)
+				body := fmt.Sprintf("-- %s\n-- Created %s\n\n", file, created)
+				if err := writeNewFile(path, body); err != nil {
+					return err
+				}
+				fmt.Printf("Created %s\n", path)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "dir", defaultMigrationsDir, "Directory to write migration files into")
+
+	return cmd
+}
+
+// nextMigrationVersion picks the version after the highest one known either
+// to the embedded set or to files already present in dir.
+func nextMigrationVersion(avail []migrations.MigrationInfo, dir string) (int, error) {
+	highest := 0
+	for _, m := range avail {
+		if m.Version > highest {
+			highest = m.Version
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	for _, e := range entries {
+		match := migrationFileRe.FindStringSubmatch(e.Name())
+		if match == nil {
+			continue
+		}
+		if v, err := strconv.Atoi(match[1]); err == nil && v > highest {
+			highest = v
+		}
+	}
+
+	return highest + 1, nil
+}
+
+func writeNewFile(path, body string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%s already exists", path)
+		}
+		return err
+	}
+	if _, err := f.WriteString(body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func newMigrateVerifyCmd() *cobra.Command {
+	var dir string
+	var record bool
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Compare applied migration checksums against their sources",
+		Long: `Compare the SHA-256 checksum of each migration source in --dir against the
+checksum stored in schema_migrations when it was applied.
+
+Migrations applied from source files record their checksum. Rows without
+one, e.g. those applied by the embedded migrator, are reported as unknown.
+Use --record to store checksums for those rows from the current sources;
+without it, verify only reads the database.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			applied, err := migrations.Applied(database)
+			if err != nil {
+				return err
+			}
+			if record {
+				if err := ensureChecksumColumn(database); err != nil {
+					return err
+				}
+			}
+			sources, err := readMigrationSources(dir)
+			if err != nil {
+				return err
+			}
+			stored, err := storedChecksums(database)
+			if err != nil {
+				return err
+			}
+
+			versions := make([]int, 0, len(applied))
+			for v := range applied {
+				versions = append(versions, v)
+			}
+			sort.Ints(versions)
+
+			changed := 0
+			for _, v := range versions {
+				src, ok := sources[v]
+				if !ok || src.Up == "" {
+					fmt.Printf("  %03d %-30s no source in %s\n", v, applied[v], dir)
+					continue
+				}
+				sum := checksum(src.Up)
+				want, ok := stored[v]
+				switch {
+				case !ok && record:
+					if _, err := execLogged(cmd.Context(), database, `UPDATE schema_migrations SET checksum = ? WHERE version = ?`, sum, v); err != nil {
+						return err
+					}
+					fmt.Printf("  %03d %-30s recorded\n", v, applied[v])
+				case !ok:
+					fmt.Printf("  %03d %-30s unknown\n", v, applied[v])
+				case want != sum:
+					changed++
+					fmt.Printf("  %03d %-30s CHANGED since applied\n", v, applied[v])
+				default:
+					fmt.Printf("  %03d %-30s ok\n", v, applied[v])
+				}
+			}
+
+			if changed > 0 {
+				return fmt.Errorf("%d applied migration(s) changed since they were applied", changed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "dir", defaultMigrationsDir, "Directory containing migration sources")
+	cmd.Flags().BoolVar(&record, "record", false, "Store checksums for applied migrations that have none")
+
+	return cmd
+}
+
+func checksum(sqlText string) string {
+	sum := sha256.Sum256([]byte(sqlText))
+	return hex.EncodeToString(sum[:])
+}
+
+func ensureChecksumColumn(database *sql.DB) error {
+	ok, err := hasColumn(database, "schema_migrations", "checksum")
+	if err != nil || ok {
+		return err
+	}
+	_, err = database.Exec(`ALTER TABLE schema_migrations ADD COLUMN checksum TEXT`)
+	return err
+}
+
+// storedChecksums returns the recorded checksums by version, or none if the
+// checksum column has never been added.
+func storedChecksums(database *sql.DB) (map[int]string, error) {
+	if ok, err := hasColumn(database, "schema_migrations", "checksum"); err != nil || !ok {
+		return map[int]string{}, err
+	}
+	rows, err := database.Query(`SELECT version, checksum FROM schema_migrations WHERE checksum IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int]string{}
+	for rows.Next() {
+		var v int
+		var sum string
+		if err := rows.Scan(&v, &sum); err != nil {
+			return nil, err
+		}
+		out[v] = sum
+	}
+	return out, rows.Err()
+}
+
+func hasColumn(database *sql.DB, table, column string) (bool, error) {
+	rows, err := database.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+func newMigrateForceCmd() *cobra.Command {
+	var yes bool
+	var dir string
+
+	cmd := &cobra.Command{
+		Use:   "force <version>",
+		Short: "Mark migrations up to a version as applied without running SQL",
+		Long: `Rewrite schema_migrations so that exactly the embedded migrations up to and
+including <version> are recorded as applied. No migration SQL is executed.
+Versions whose up script is found in --dir are recorded with its checksum,
+so migrate verify can check them later.
+
+This is an escape hatch for repairing a dirty migration state and requires --yes.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid version %q", args[0])
+			}
+
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			avail, err := migrations.Embedded()
+			if err != nil {
+				return err
+			}
+			applied, err := migrations.Applied(database)
+			if err != nil {
+				return err
+			}
+
+			var inserts, deletes []migrations.MigrationInfo
+			known := target == 0
+			for _, m := range avail {
+				if m.Version == target {
+					known = true
+				}
+				if _, ok := applied[m.Version]; !ok && m.Version <= target {
+					inserts = append(inserts, m)
+				}
+			}
+			if !known {
+				return fmt.Errorf("migration %03d not found in embedded migrations", target)
+			}
+			for v, name := range applied {
+				if v > target {
+					deletes = append(deletes, migrations.MigrationInfo{Version: v, Name: name})
+				}
+			}
+			sort.Slice(deletes, func(i, j int) bool { return deletes[i].Version < deletes[j].Version })
+
+			if len(inserts) == 0 && len(deletes) == 0 {
+				fmt.Printf("schema_migrations already matches %03d.\n", target)
+				return nil
+			}
+			for _, m := range inserts {
+				fmt.Printf("  + %03d %s\n", m.Version, m.Name)
+			}
+			for _, m := range deletes {
+				fmt.Printf("  - %03d %s\n", m.Version, m.Name)
+			}
+			if !yes {
+				return fmt.Errorf("refusing to rewrite schema_migrations without --yes")
+			}
+
+			sources, err := readMigrationSources(dir)
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("read migration sources: %w", err)
+			}
+			if len(inserts) > 0 {
+				if err := ensureChecksumColumn(database); err != nil {
+					return err
+				}
+			}
+
+			tx, err := database.Begin()
+			if err != nil {
+				return err
+			}
+			defer tx.Rollback()
+			for _, m := range inserts {
+				if src := sources[m.Version]; src.Up != "" {
+					src.Name = m.Name
+					err = recordMigration(cmd.Context(), tx, src)
+				} else {
+					_, err = execLogged(cmd.Context(), tx, `INSERT INTO schema_migrations(version, name) VALUES(?, ?)`, m.Version, m.Name)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			for _, m := range deletes {
+				if _, err := execLogged(cmd.Context(), tx, `DELETE FROM schema_migrations WHERE version = ?`, m.Version); err != nil {
+					return err
+				}
+			}
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			fmt.Printf("Forced migration state to %03d.\n", target)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm rewriting schema_migrations")
+	cmd.Flags().StringVar(&dir, "dir", defaultMigrationsDir, "Directory containing migration sources to take checksums from")
+
+	return cmd
+}
+
+type migrationStatus struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	Applied   bool   `json:"applied"`
+	AppliedAt string `json:"applied_at,omitempty"`
+}
+
+// migrationAppliedAt reads the applied_at column that arc-sdk fills in but
+// does not return from migrations.Applied. Rows with a NULL timestamp are
+// left out of the map.
+func migrationAppliedAt(database *sql.DB) (map[int]string, error) {
+	rows, err := database.Query(`SELECT version, applied_at FROM schema_migrations WHERE applied_at IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int]string{}
+	for rows.Next() {
+		var v int
+		var at string
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		out[v] = at
+	}
+	return out, rows.Err()
+}
+
+func appliedAtOrDash(appliedAt map[int]string, version int) string {
+	if at, ok := appliedAt[version]; ok {
+		return at
+	}
+	return "-"
+}
+
+func pendingMigrations(avail []migrations.MigrationInfo, applied map[int]string) []migrations.MigrationInfo {
+	var out []migrations.MigrationInfo
+	for _, m := range avail {
+		if _, ok := applied[m.Version]; !ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// planMigrateTo returns the migrations to touch, in execution order, to move
+// from the applied set to target, and whether that is an upgrade.
+func planMigrateTo(avail []migrations.MigrationInfo, applied map[int]string, target int) ([]migrations.MigrationInfo, bool, error) {
+	// Version 0 is the empty schema, before any migration.
+	known := target == 0
+	for _, m := range avail {
+		if m.Version == target {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return nil, false, fmt.Errorf("migration %03d not found in embedded migrations", target)
+	}
+
+	current := 0
+	for v := range applied {
+		if v > current {
+			current = v
+		}
+	}
+
+	var steps []migrations.MigrationInfo
+	if target >= current {
+		for _, m := range avail {
+			if _, ok := applied[m.Version]; !ok && m.Version <= target {
+				steps = append(steps, m)
+			}
+		}
+		sort.Slice(steps, func(i, j int) bool { return steps[i].Version < steps[j].Version })
+		return steps, true, nil
+	}
+
+	for v, name := range applied {
+		if v > target {
+			steps = append(steps, migrations.MigrationInfo{Version: v, Name: name})
+		}
+	}
+	sort.Slice(steps, func(i, j int) bool { return steps[i].Version > steps[j].Version })
+	return steps, false, nil
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const lockPollInterval = 250 * time.Millisecond
+
+// migrationLockTable holds the migration lock row. Export never includes it.
+const migrationLockTable = "schema_migrations_lock"
+
+// staleLockAge is how long a migration lock may be held before another run
+// takes it over, assuming its holder died without releasing it.
+const staleLockAge = time.Hour
+
+// errMigrationsLocked is returned when another process holds the migration
+// lock for longer than the caller is willing to wait.
+var errMigrationsLocked = errors.New("migrations locked")
+
+// migrationLock is the row of schema_migrations_lock. holder is
+// "host:pid" of the process that took the lock.
+type migrationLock struct {
+	holder     string
+	acquiredAt int64
+}
+
+func (l migrationLock) String() string {
+	return fmt.Sprintf("%s since %s", l.holder, time.Unix(l.acquiredAt, 0).Format(time.RFC3339))
+}
+
+// staleReason says why l can be taken over, or returns "" if its holder may
+// still be running: the lock is older than staleLockAge, or its holder is a
+// process on this host that has exited.
+func (l migrationLock) staleReason(now time.Time) string {
+	if age := now.Sub(time.Unix(l.acquiredAt, 0)); age > staleLockAge {
+		return fmt.Sprintf("held for %s", age.Round(time.Second))
+	}
+	i := strings.LastIndexByte(l.holder, ':')
+	if i < 0 {
+		return ""
+	}
+	pid, err := strconv.Atoi(l.holder[i+1:])
+	if err != nil {
+		return ""
+	}
+	if host, _ := os.Hostname(); host != l.holder[:i] || processAlive(pid) {
+		return ""
+	}
+	return fmt.Sprintf("process %d is no longer running", pid)
+}
+
+// processAlive reports whether pid exists on this host. Anything other than
+// a definite "no such process" counts as alive, so a lock is never taken
+// from a holder that may be running.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return !errors.Is(err, os.ErrProcessDone) && !errors.Is(err, syscall.ESRCH)
+}
+
+func ensureMigrationLockTable(ctx context.Context, database *sql.DB) error {
+	_, err := execLogged(ctx, database, `CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		holder TEXT NOT NULL,
+		acquired_at INTEGER NOT NULL
+	)`)
+	return err
+}
+
+// currentMigrationLock returns the lock row, or false if nobody holds it.
+func currentMigrationLock(database *sql.DB) (migrationLock, bool, error) {
+	var l migrationLock
+	err := database.QueryRow(`SELECT holder, acquired_at FROM schema_migrations_lock WHERE id = 1`).Scan(&l.holder, &l.acquiredAt)
+	if err == sql.ErrNoRows {
+		return l, false, nil
+	}
+	return l, err == nil, err
+}
+
+// acquireMigrationLock takes the single-row schema_migrations_lock, waiting
+// up to timeout for a concurrent holder to finish. A stale lock, left by a
+// holder that crashed, is taken over with a warning. The returned func
+// releases the lock.
+func acquireMigrationLock(ctx context.Context, database *sql.DB, timeout time.Duration) (func(), error) {
+	if err := ensureMigrationLockTable(ctx, database); err != nil {
+		return nil, err
+	}
+
+	host, _ := os.Hostname()
+	holder := fmt.Sprintf("%s:%d", host, os.Getpid())
+	deadline := time.Now().Add(timeout)
+
+	for {
+		_, err := execLogged(ctx, database, `INSERT INTO schema_migrations_lock(id, holder, acquired_at) VALUES(1, ?, ?)`, holder, time.Now().Unix())
+		if err == nil {
+			release := func() {
+				execLogged(context.Background(), database, `DELETE FROM schema_migrations_lock WHERE id = 1 AND holder = ?`, holder)
+			}
+			return release, nil
+		}
+		if !strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, fmt.Errorf("acquire migration lock: %w", err)
+		}
+
+		other, held, err := currentMigrationLock(database)
+		if err != nil {
+			return nil, fmt.Errorf("acquire migration lock: %w", err)
+		}
+		if !held {
+			// Released since the insert failed; try again straight away.
+			continue
+		}
+		if reason := other.staleReason(time.Now()); reason != "" {
+			// Only delete the row we judged stale, in case another run
+			// has just taken it over.
+			res, err := execLogged(ctx, database, `DELETE FROM schema_migrations_lock WHERE id = 1 AND holder = ? AND acquired_at = ?`, other.holder, other.acquiredAt)
+			if err != nil {
+				return nil, fmt.Errorf("take over stale migration lock: %w", err)
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				fmt.Fprintf(os.Stderr, "warning: took over stale migration lock held by %s (%s)\n", other, reason)
+			}
+			continue
+		}
+
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("%w by %s (arc-db migrate unlock clears a lock left by a crashed run)", errMigrationsLocked, other)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+func newMigrateUnlockCmd() *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "unlock",
+		Short: "Clear a migration lock left behind by a crashed run",
+		Long: `Delete the schema_migrations_lock row so that migrations can run again.
+migrate up takes over locks that are older than an hour or whose holder was
+a process on this host that has exited; use unlock for other cases, after
+making sure the holder is not still migrating. Requires --yes.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			if ok, err := checkTable(database, migrationLockTable); err != nil {
+				return err
+			} else if !ok {
+				fmt.Println("Migrations are not locked.")
+				return nil
+			}
+			l, held, err := currentMigrationLock(database)
+			if err != nil {
+				return err
+			}
+			if !held {
+				fmt.Println("Migrations are not locked.")
+				return nil
+			}
+			fmt.Printf("Migration lock held by %s\n", l)
+			if !yes {
+				return fmt.Errorf("refusing to clear the migration lock without --yes")
+			}
+			if _, err := execLogged(cmd.Context(), database, `DELETE FROM schema_migrations_lock WHERE id = 1 AND holder = ? AND acquired_at = ?`, l.holder, l.acquiredAt); err != nil {
+				return err
+			}
+			fmt.Println("Cleared the migration lock.")
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm clearing the lock")
+
+	return cmd
+}
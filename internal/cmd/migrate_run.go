@@ -0,0 +1,443 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yourorg/arc-sdk/db/migrations"
+)
+
+// migrationsTable records applied migrations. It is migration state rather
+// than data, so export and import leave it out unless asked.
+const migrationsTable = "schema_migrations"
+
+// noTransactionDirective, placed in a migration's header comments, runs its
+// statements outside a transaction (needed for e.g. VACUUM).
+const noTransactionDirective = "-- arc:no-transaction"
+
+type migrationSource struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// readMigrationSources loads the migration files in dir keyed by version.
+// Plain NNN_name.sql files count as up migrations.
+func readMigrationSources(dir string) (map[int]migrationSource, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	out := map[int]migrationSource{}
+	for _, e := range entries {
+		match := migrationFileRe.FindStringSubmatch(e.Name())
+		if match == nil || e.IsDir() {
+			continue
+		}
+		v, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		src := out[v]
+		src.Version = v
+		src.Name = match[2]
+		if match[3] == "down" {
+			src.Down = string(b)
+		} else {
+			src.Up = string(b)
+		}
+		out[v] = src
+	}
+	return out, nil
+}
+
+// migrationsFromDir lists the migrations in dir in version order, in the
+// same form as migrations.Embedded, along with their sources. Files follow
+// the embedded NNN_name.sql convention; a version without an up script, two
+// names for one version, or a name that migrate create would reject is an
+// error.
+func migrationsFromDir(dir string) ([]migrations.MigrationInfo, map[int]migrationSource, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := map[int]string{}
+	for _, e := range entries {
+		match := migrationFileRe.FindStringSubmatch(e.Name())
+		if match == nil || e.IsDir() {
+			continue
+		}
+		v, err := strconv.Atoi(match[1])
+		if err != nil || v <= 0 {
+			return nil, nil, fmt.Errorf("%s: invalid migration version %q", e.Name(), match[1])
+		}
+		if !migrationNameRe.MatchString(match[2]) {
+			return nil, nil, fmt.Errorf("%s: invalid migration name %q", e.Name(), match[2])
+		}
+		if prev, ok := names[v]; ok && prev != match[2] {
+			return nil, nil, fmt.Errorf("migration %03d has two names in %s: %s and %s", v, dir, prev, match[2])
+		}
+		names[v] = match[2]
+	}
+
+	sources, err := readMigrationSources(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	avail := make([]migrations.MigrationInfo, 0, len(sources))
+	for v, src := range sources {
+		if src.Up == "" {
+			return nil, nil, fmt.Errorf("no up script for %03d_%s in %s", v, src.Name, dir)
+		}
+		avail = append(avail, migrations.MigrationInfo{Version: v, Name: src.Name})
+	}
+	if len(avail) == 0 {
+		return nil, nil, fmt.Errorf("no migrations found in %s", dir)
+	}
+	sort.Slice(avail, func(i, j int) bool { return avail[i].Version < avail[j].Version })
+	return avail, sources, nil
+}
+
+// loadMigrations returns migrations.Embedded, or the migrations in dir when
+// one is given. Sources are only returned for a directory; embedded
+// migrations are applied by migrations.RunMigrations.
+func loadMigrations(dir string) ([]migrations.MigrationInfo, map[int]migrationSource, error) {
+	if dir == "" {
+		avail, err := migrations.Embedded()
+		return avail, nil, err
+	}
+	return migrationsFromDir(dir)
+}
+
+// embeddedMigratorWarning is printed when embedded migrations have to go
+// through the arc-sdk migrator.
+const embeddedMigratorWarning = "warning: embedded migration SQL not found; the arc-sdk migrator applies them in one call, " +
+	"without per-migration transactions, " + noTransactionDirective + ", statement errors or checksums"
+
+// embeddedSources returns the SQL for the embedded migrations in pending
+// from dir, or nil unless every one of them has an up script there under
+// the same name. arc-sdk does not expose the embedded SQL, so a checkout's
+// copy is the only way to apply them one at a time.
+func embeddedSources(dir string, pending []migrations.MigrationInfo) map[int]migrationSource {
+	sources, err := readMigrationSources(dir)
+	if err != nil {
+		return nil
+	}
+	for _, m := range pending {
+		if src, ok := sources[m.Version]; !ok || src.Up == "" || src.Name != m.Name {
+			return nil
+		}
+	}
+	return sources
+}
+
+// ensureMigrationsTable creates schema_migrations with the columns arc-sdk
+// uses, for databases that have only ever been migrated from a directory.
+func ensureMigrationsTable(ctx context.Context, database *sql.DB) error {
+	_, err := execLogged(ctx, database, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TEXT DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+func applyMigration(ctx context.Context, database *sql.DB, src migrationSource) error {
+	if err := ensureChecksumColumn(database); err != nil {
+		return err
+	}
+	err := runMigrationSQL(ctx, database, src.Up, func(ex execer) error {
+		return recordMigration(ctx, ex, src)
+	})
+	if err != nil {
+		return fmt.Errorf("apply migration %03d_%s: %w", src.Version, src.Name, err)
+	}
+	return nil
+}
+
+// recordMigration marks src as applied, storing the checksum of its up
+// script so migrate verify can tell if the file is edited afterwards. The
+// checksum column must already exist.
+func recordMigration(ctx context.Context, ex execer, src migrationSource) error {
+	_, err := execLogged(ctx, ex, `INSERT INTO schema_migrations(version, name, checksum) VALUES(?, ?, ?)`,
+		src.Version, src.Name, checksum(src.Up))
+	return err
+}
+
+// dryRunMigrations prints the version, name and SQL of each pending
+// migration, then runs them all in one transaction that is rolled back, so
+// SQL errors surface without touching the database or schema_migrations.
+// Migrations with no source in sources are printed without SQL, and
+// no-transaction migrations are printed but not run.
+func dryRunMigrations(ctx context.Context, database *sql.DB, pending []migrations.MigrationInfo, sources map[int]migrationSource) error {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, m := range pending {
+		fmt.Printf("-- %03d %s\n", m.Version, m.Name)
+		src, ok := sources[m.Version]
+		if !ok || src.Up == "" {
+			fmt.Printf("-- (no source found; pass --migrations-dir to show and check its SQL)\n\n")
+			continue
+		}
+		fmt.Println(strings.TrimRight(src.Up, "\n"))
+		fmt.Println()
+		if hasNoTransactionDirective(src.Up) {
+			fmt.Fprintf(os.Stderr, "warning: %03d_%s runs outside a transaction and was not checked\n", m.Version, m.Name)
+			continue
+		}
+		if err := execScript(ctx, tx, src.Up); err != nil {
+			return fmt.Errorf("dry run of migration %03d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+	fmt.Printf("Dry run: %d migration(s) would be applied; nothing was changed.\n", len(pending))
+	return nil
+}
+
+func revertMigration(ctx context.Context, database *sql.DB, src migrationSource) error {
+	err := runMigrationSQL(ctx, database, src.Down, func(ex execer) error {
+		_, err := execLogged(ctx, ex, `DELETE FROM schema_migrations WHERE version = ?`, src.Version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("revert migration %03d_%s: %w", src.Version, src.Name, err)
+	}
+	return nil
+}
+
+// redoMigrations reverts srcs, given newest first, and then re-applies them
+// oldest first, all in one transaction. When any script opts out of
+// transactions each step runs in its own instead, as migrate to would.
+func redoMigrations(ctx context.Context, database *sql.DB, srcs []migrationSource) error {
+	if err := ensureChecksumColumn(database); err != nil {
+		return err
+	}
+	for _, src := range srcs {
+		if hasNoTransactionDirective(src.Up) || hasNoTransactionDirective(src.Down) {
+			for _, src := range srcs {
+				if err := revertMigration(ctx, database, src); err != nil {
+					return err
+				}
+			}
+			for i := len(srcs) - 1; i >= 0; i-- {
+				if err := applyMigration(ctx, database, srcs[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, src := range srcs {
+		if err := execScript(ctx, tx, src.Down); err != nil {
+			return fmt.Errorf("revert migration %03d_%s: %w", src.Version, src.Name, err)
+		}
+		if _, err := execLogged(ctx, tx, `DELETE FROM schema_migrations WHERE version = ?`, src.Version); err != nil {
+			return err
+		}
+	}
+	for i := len(srcs) - 1; i >= 0; i-- {
+		src := srcs[i]
+		if err := execScript(ctx, tx, src.Up); err != nil {
+			return fmt.Errorf("apply migration %03d_%s: %w", src.Version, src.Name, err)
+		}
+		if err := recordMigration(ctx, tx, src); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// runMigrationSQL executes each statement of sqlText followed by record, all
+// inside one transaction unless the no-transaction directive is present.
+// Cancelling ctx interrupts the running statement and rolls the transaction
+// back.
+func runMigrationSQL(ctx context.Context, database *sql.DB, sqlText string, record func(execer) error) error {
+	stmts, err := splitStatements(sqlText)
+	if err != nil {
+		return err
+	}
+
+	if hasNoTransactionDirective(sqlText) {
+		if err := execStatements(ctx, database, stmts); err != nil {
+			return err
+		}
+		return record(database)
+	}
+
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := execStatements(ctx, tx, stmts); err != nil {
+		return err
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func execStatements(ctx context.Context, ex execer, stmts []string) error {
+	for i, stmt := range stmts {
+		if _, err := execLogged(ctx, ex, stmt); err != nil {
+			return fmt.Errorf("statement %d (%s): %w", i+1, summarizeStatement(stmt), err)
+		}
+	}
+	return nil
+}
+
+func hasNoTransactionDirective(sqlText string) bool {
+	sc := bufio.NewScanner(strings.NewReader(sqlText))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			return false
+		}
+		if line == noTransactionDirective {
+			return true
+		}
+	}
+	return false
+}
+
+// execScript splits sqlText into statements and runs them on ex.
+func execScript(ctx context.Context, ex execer, sqlText string) error {
+	stmts, err := splitStatements(sqlText)
+	if err != nil {
+		return err
+	}
+	return execStatements(ctx, ex, stmts)
+}
+
+// splitStatements splits a SQL script on top-level semicolons, keeping
+// quoted strings, comments and CREATE TRIGGER ... END bodies intact. A
+// string, quoted identifier or block comment left open is an error.
+func splitStatements(sqlText string) ([]string, error) {
+	var stmts []string
+	var cur strings.Builder
+
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" && !isOnlyComments(s) {
+			stmts = append(stmts, s)
+		}
+		cur.Reset()
+	}
+
+	for i := 0; i < len(sqlText); i++ {
+		c := sqlText[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := strings.IndexByte(sqlText[i+1:], c)
+			if end < 0 {
+				kind := "string"
+				if c != '\'' {
+					kind = "quoted identifier"
+				}
+				return nil, fmt.Errorf("unterminated %s in statement %d", kind, len(stmts)+1)
+			}
+			cur.WriteString(sqlText[i : i+end+2])
+			i += end + 1
+		case c == '-' && strings.HasPrefix(sqlText[i:], "--"):
+			end := strings.IndexByte(sqlText[i:], '\n')
+			if end < 0 {
+				end = len(sqlText) - i
+			}
+			cur.WriteString(sqlText[i : i+end])
+			i += end - 1
+		case c == '/' && strings.HasPrefix(sqlText[i:], "/*"):
+			end := strings.Index(sqlText[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment in statement %d", len(stmts)+1)
+			}
+			cur.WriteString(sqlText[i : i+end+4])
+			i += end + 3
+		case c == ';':
+			cur.WriteByte(c)
+			if !insideTrigger(cur.String()) {
+				flush()
+			}
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts, nil
+}
+
+func insideTrigger(stmt string) bool {
+	fields := strings.Fields(strings.ToUpper(stripComments(stmt)))
+	isTrigger := false
+	for i, f := range fields {
+		if f == "TRIGGER" && i > 0 && fields[0] == "CREATE" {
+			isTrigger = true
+			break
+		}
+	}
+	if !isTrigger {
+		return false
+	}
+	last := strings.TrimSuffix(fields[len(fields)-1], ";")
+	if last == "" && len(fields) > 1 {
+		last = fields[len(fields)-2]
+	}
+	return last != "END"
+}
+
+func stripComments(stmt string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(stmt, "\n") {
+		if idx := strings.Index(line, "--"); idx >= 0 {
+			line = line[:idx]
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func isOnlyComments(stmt string) bool {
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(stripComments(stmt)), ";")) == ""
+}
+
+func summarizeStatement(stmt string) string {
+	s := strings.Join(strings.Fields(stripComments(stmt)), " ")
+	if len(s) > 60 {
+		s = s[:57] + "..."
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "two statements",
+			sql:  "CREATE TABLE a(x);\nCREATE TABLE b(y);\n",
+			want: []string{"CREATE TABLE a(x);", "CREATE TABLE b(y);"},
+		},
+		{
+			name: "semicolon in string",
+			sql:  "INSERT INTO a VALUES ('x;y');",
+			want: []string{"INSERT INTO a VALUES ('x;y');"},
+		},
+		{
+			name: "line comment at end of file",
+			sql:  "CREATE TABLE a(x);\n-- trailing; note",
+			want: []string{"CREATE TABLE a(x);"},
+		},
+		{
+			name: "block comment with semicolon",
+			sql:  "CREATE TABLE a(x); /* not; a statement */ CREATE TABLE b(y);",
+			want: []string{"CREATE TABLE a(x);", "/* not; a statement */ CREATE TABLE b(y);"},
+		},
+		{
+			name: "trigger body",
+			sql: "CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  UPDATE a SET x = 1;\n  DELETE FROM b;\nEND;\n" +
+				"CREATE TABLE c(z);",
+			want: []string{
+				"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  UPDATE a SET x = 1;\n  DELETE FROM b;\nEND;",
+				"CREATE TABLE c(z);",
+			},
+		},
+		{
+			name:    "unterminated string",
+			sql:     "CREATE TABLE a(x);\nINSERT INTO a VALUES ('unterminated);",
+			wantErr: "unterminated string in statement 2",
+		},
+		{
+			name:    "unterminated quoted identifier",
+			sql:     `SELECT "x FROM a;`,
+			wantErr: "unterminated quoted identifier in statement 1",
+		},
+		{
+			name:    "unterminated comment",
+			sql:     "CREATE TABLE b(x); /* open comment",
+			wantErr: "unterminated comment in statement 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitStatements(tt.sql)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
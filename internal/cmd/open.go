@@ -0,0 +1,228 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yourorg/arc-sdk/db"
+	"github.com/yourorg/arc-sdk/db/migrations"
+)
+
+// busyTimeoutFlag, walFlag, noWALFlag and noForeignKeysFlag hold the
+// persistent --busy-timeout, --wal, --no-wal and --no-foreign-keys flags, the
+// defaults for every open.
+var (
+	busyTimeoutFlag   = 5 * time.Second
+	walFlag           bool
+	noWALFlag         bool
+	noForeignKeysFlag bool
+)
+
+// openOptions controls the per-connection pragmas openDB applies and how
+// its pool is sized.
+type openOptions struct {
+	busyTimeout time.Duration
+	wal         *bool
+	foreignKeys bool
+
+	maxOpenConns int
+}
+
+type openOption func(*openOptions)
+
+// withBusyTimeout sets how long a connection waits on a locked database
+// before failing with "database is locked".
+func withBusyTimeout(d time.Duration) openOption {
+	return func(o *openOptions) { o.busyTimeout = d }
+}
+
+// withWAL turns write-ahead logging on or off. WAL lets readers proceed
+// while a writer is active, at the cost of -wal and -shm sidecar files next
+// to the database that must be kept with it. The journal mode is stored in
+// the file, so this changes it for every later open too.
+func withWAL(on bool) openOption {
+	return func(o *openOptions) { o.wal = &on }
+}
+
+// withForeignKeys turns foreign key enforcement on or off. SQLite leaves it
+// off unless each connection asks for it; turning it off speeds up bulk
+// loads whose rows arrive out of dependency order.
+func withForeignKeys(on bool) openOption {
+	return func(o *openOptions) { o.foreignKeys = on }
+}
+
+// withMaxOpenConns caps the pool at n connections; 0 removes the cap.
+// openDB defaults to 1: SQLite allows one writer at a time, so writes then
+// queue in the pool instead of contending for the file lock and failing
+// with "database is locked" once busy_timeout runs out. A caller that
+// mostly reads in WAL mode can allow more, as readers do not block each
+// other or the writer. With a single connection, a caller that holds a
+// *sql.Conn, an open transaction or unclosed rows must not issue further
+// statements on the pool, or it waits on itself.
+func withMaxOpenConns(n int) openOption {
+	return func(o *openOptions) { o.maxOpenConns = n }
+}
+
+// openDB opens path for reading and writing, creating its parent directory
+// (mode 0700) first so a fresh install bootstraps cleanly. db.Open would
+// otherwise create it world-readable.
+//
+// With no options it uses the --busy-timeout, --wal, --no-wal and
+// --no-foreign-keys flags, which default to db.Open's own settings, except
+// that an existing database keeps its journal mode unless --wal, --no-wal
+// or withWAL asks for another. db.Open sets its pragmas on a single pooled
+// connection only, so the options are also passed as _pragma DSN
+// parameters, which the driver applies to every new connection.
+func openDB(path string, opts ...openOption) (*sql.DB, error) {
+	var o openOptions
+	defaults := []openOption{withBusyTimeout(busyTimeoutFlag), withForeignKeys(!noForeignKeysFlag), withMaxOpenConns(1)}
+	if walFlag || noWALFlag {
+		defaults = append(defaults, withWAL(walFlag))
+	}
+	for _, opt := range append(defaults, opts...) {
+		opt(&o)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create database directory: %w", err)
+	}
+
+	// db.Open always switches to WAL, so leaving the mode alone means
+	// switching an existing rollback-journal database back afterwards.
+	wal := true
+	if o.wal != nil {
+		wal = *o.wal
+	} else if inWAL, ok := fileInWALMode(path); ok {
+		wal = inWAL
+	}
+	journal := "DELETE"
+	if wal {
+		journal = "WAL"
+	}
+	foreignKeys := "OFF"
+	if o.foreignKeys {
+		foreignKeys = "ON"
+	}
+	pragmas := []string{
+		fmt.Sprintf("busy_timeout(%d)", o.busyTimeout.Milliseconds()),
+		fmt.Sprintf("journal_mode(%s)", journal),
+		fmt.Sprintf("foreign_keys(%s)", foreignKeys),
+	}
+	q := url.Values{"_pragma": pragmas}
+	database, err := db.Open(path + "?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	database.SetMaxOpenConns(o.maxOpenConns)
+	// db.Open's own pragmas ran on the connection now idle in the pool;
+	// override them there too.
+	for _, p := range []string{
+		fmt.Sprintf("PRAGMA busy_timeout = %d", o.busyTimeout.Milliseconds()),
+		"PRAGMA journal_mode = " + journal,
+		"PRAGMA foreign_keys = " + foreignKeys,
+	} {
+		if _, err := database.Exec(p); err != nil {
+			database.Close()
+			return nil, err
+		}
+	}
+	return database, nil
+}
+
+// fileInWALMode reports whether the database at path is in WAL mode, read
+// from the file format bytes of its header. ok is false if path is missing
+// or not yet a database.
+func fileInWALMode(path string) (wal, ok bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, false
+	}
+	defer f.Close()
+	header := make([]byte, 20)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false, false
+	}
+	if string(header[:16]) != "SQLite format 3\x00" {
+		return false, false
+	}
+	// Bytes 18 and 19 are the write and read versions: 1 for a rollback
+	// journal, 2 for WAL.
+	return header[18] == 2, true
+}
+
+// openDBContext is openDB for callers that can be cancelled. db.Open takes
+// no context, so ctx bounds the initial connection check; statements must
+// still use the *Context methods to be interruptible.
+func openDBContext(ctx context.Context, path string, opts ...openOption) (*sql.DB, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	database, err := openDB(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.PingContext(ctx); err != nil {
+		database.Close()
+		return nil, err
+	}
+	return database, nil
+}
+
+// openReadOnly opens path with mode=ro so read commands never take write
+// locks or create the file. With immutable, SQLite also skips locking and
+// change detection entirely, which is only safe for files no one is writing.
+// db.Open has no read-only variant, so this opens the driver directly.
+func openReadOnly(path string, immutable bool) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("database %s does not exist", path)
+		}
+		return nil, err
+	}
+
+	return sql.Open("sqlite", readOnlyDSN(path, immutable))
+}
+
+// readOnlyDSN builds a file: URI that opens path read-only, with the
+// --busy-timeout so readers wait out a checkpoint.
+func readOnlyDSN(path string, immutable bool) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	query := fmt.Sprintf("mode=ro&_pragma=busy_timeout(%d)", busyTimeoutFlag.Milliseconds())
+	if immutable {
+		query += "&immutable=1"
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path), RawQuery: query}
+	return u.String()
+}
+
+// openMemory opens a private in-memory database, optionally with the
+// embedded migrations applied. The pool is pinned to one connection because
+// each connection to :memory: gets its own empty database. Code that needs
+// several connections to see the same data must use
+// file::memory:?cache=shared instead, which is visible to every connection in
+// the process and lives until the last one closes.
+func openMemory(migrate bool) (*sql.DB, error) {
+	database, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	database.SetMaxOpenConns(1)
+	if migrate {
+		if err := migrations.RunMigrations(database); err != nil {
+			database.Close()
+			return nil, fmt.Errorf("migrate in-memory database: %w", err)
+		}
+	}
+	return database, nil
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenCreatesParentDirectories(t *testing.T) {
+	tests := []struct {
+		name string
+		dirs []string
+	}{
+		{name: "existing directory"},
+		{name: "one missing", dirs: []string{"arc"}},
+		{name: "several missing", dirs: []string{"a", "b", "c", "d", "arc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(append([]string{root}, tt.dirs...)...)
+			path := filepath.Join(dir, "arc.db")
+
+			// Read-only opens must not create anything.
+			if _, err := openReadOnly(path, false); err == nil {
+				t.Fatal("openReadOnly of a missing database succeeded")
+			}
+			if len(tt.dirs) > 0 {
+				if _, err := os.Stat(filepath.Join(root, tt.dirs[0])); !os.IsNotExist(err) {
+					t.Fatalf("openReadOnly created %s (stat err %v)", tt.dirs[0], err)
+				}
+			}
+
+			database, err := openDB(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer database.Close()
+			if _, err := database.Exec("CREATE TABLE t (id INTEGER)"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(path); err != nil {
+				t.Fatal(err)
+			}
+			for i := range tt.dirs {
+				d := filepath.Join(append([]string{root}, tt.dirs[:i+1]...)...)
+				fi, err := os.Stat(d)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if perm := fi.Mode().Perm(); perm != 0o700 {
+					t.Errorf("%s has mode %o, want 700", d, perm)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenEnforcesForeignKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []openOption
+		holdConn bool
+		wantErr  bool
+	}{
+		{name: "default", wantErr: true},
+		{name: "enabled", opts: []openOption{withForeignKeys(true)}, wantErr: true},
+		{name: "disabled for bulk loads", opts: []openOption{withForeignKeys(false)}},
+		// Each pooled connection must get the pragma, not just the first.
+		{name: "several connections", opts: []openOption{withMaxOpenConns(4)}, holdConn: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, err := openDB(filepath.Join(t.TempDir(), "arc.db"), tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer database.Close()
+			for _, s := range []string{
+				`CREATE TABLE repos (name TEXT PRIMARY KEY)`,
+				`CREATE TABLE repo_dependencies (repo_name TEXT NOT NULL REFERENCES repos (name), dependency_name TEXT)`,
+				`INSERT INTO repos VALUES ('arc')`,
+				`INSERT INTO repo_dependencies VALUES ('arc', 'cobra')`,
+			} {
+				if _, err := database.Exec(s); err != nil {
+					t.Fatalf("%s: %v", s, err)
+				}
+			}
+
+			if tt.holdConn {
+				// Hold a connection so the insert below runs on another one.
+				conn, err := database.Conn(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer conn.Close()
+			}
+
+			_, err = database.Exec(`INSERT INTO repo_dependencies VALUES ('missing', 'cobra')`)
+			if tt.wantErr && err == nil {
+				t.Error("insert violating a foreign key succeeded")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("insert with foreign keys off: %v", err)
+			}
+		})
+	}
+}
+
+func TestOpenPoolSize(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []openOption
+		want int
+	}{
+		{name: "default", want: 1},
+		{name: "read-heavy caller", opts: []openOption{withMaxOpenConns(8)}, want: 8},
+		{name: "uncapped", opts: []openOption{withMaxOpenConns(0)}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, err := openDB(filepath.Join(t.TempDir(), "arc.db"), tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer database.Close()
+			if got := database.Stats().MaxOpenConnections; got != tt.want {
+				t.Errorf("MaxOpenConnections = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
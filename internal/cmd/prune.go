@@ -0,0 +1,296 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// sessionTimeColumns are tried in order when --column is not given.
+var sessionTimeColumns = []string{"last_ts", "mod_ts", "create_ts", "updated_at", "created_at"}
+
+func newPruneCmd() *cobra.Command {
+	pc := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete old rows",
+		RunE:  func(cmd *cobra.Command, args []string) error { return cmd.Help() },
+	}
+
+	var olderThan string
+	var column string
+	var dryRun bool
+	var after afterMutation
+	sessionsCmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "Delete sessions older than a given age",
+		Long: `Delete sessions whose timestamp column is older than --older-than.
+
+The column defaults to the first of last_ts, mod_ts, create_ts, updated_at
+and created_at that exists. Both unix timestamps and SQLite date
+strings are understood. --vacuum-after and --analyze-after run VACUUM and
+ANALYZE once the rows are deleted; neither runs with --dry-run.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if olderThan == "" {
+				return fmt.Errorf("--older-than is required")
+			}
+			age, err := parseAge(olderThan)
+			if err != nil {
+				return err
+			}
+
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			if column == "" {
+				cols, err := tableColumns(database, "sessions")
+				if err != nil {
+					return err
+				}
+				if len(cols) == 0 {
+					return fmt.Errorf("no sessions table")
+				}
+				if column = firstColumn(cols, sessionTimeColumns); column == "" {
+					return fmt.Errorf("no timestamp column found in sessions (columns: %s); pass --column", strings.Join(cols, ", "))
+				}
+			}
+
+			cutoff := time.Now().Add(-age)
+			n, err := pruneRows(database, "sessions", column, cutoff, dryRun)
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				fmt.Printf("Would delete %d sessions with %s before %s\n", n, column, cutoff.UTC().Format(time.RFC3339))
+				return nil
+			}
+			fmt.Printf("Deleted %d sessions with %s before %s\n", n, column, cutoff.UTC().Format(time.RFC3339))
+			return after.run(cmd.Context(), database)
+		},
+	}
+	sessionsCmd.Flags().StringVar(&olderThan, "older-than", "", "Delete rows older than this age, e.g. 30d, 12h, 1d6h")
+	sessionsCmd.Flags().StringVar(&column, "column", "", "Timestamp column to compare (default: auto-detect)")
+	sessionsCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Count matching rows without deleting them")
+	after.addFlags(sessionsCmd)
+	pc.AddCommand(sessionsCmd)
+
+	return pc
+}
+
+var ageDaysRe = regexp.MustCompile(`^(\d+)d(.*)$`)
+
+// parseAge parses a duration like time.ParseDuration, additionally
+// accepting a leading day count such as "30d" or "1d12h".
+func parseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	var days time.Duration
+	if m := ageDaysRe.FindStringSubmatch(s); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		s = m[2]
+		if s == "" {
+			return days, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q (want e.g. 30d, 12h, 45m)", s)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid age %q: must not be negative", s)
+	}
+	return days + d, nil
+}
+
+// pruneRows deletes (or with dryRun, counts) rows of table whose column is
+// before cutoff, in a single transaction. Numeric values are compared as
+// unix seconds and text values as SQLite date strings.
+func pruneRows(database *sql.DB, table, column string, cutoff time.Time, dryRun bool) (int64, error) {
+	if err := checkPruneColumn(database, table, column); err != nil {
+		return 0, err
+	}
+
+	col := quoteIdent(column)
+	where := fmt.Sprintf(`CASE WHEN typeof(%s) IN ('integer', 'real') THEN %s < ? ELSE julianday(%s) < julianday(?) END`, col, col, col)
+	args := []any{cutoff.Unix(), cutoff.UTC().Format("2006-01-02 15:04:05")}
+
+	tx, err := database.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if dryRun {
+		var n int64
+		err := tx.QueryRow("SELECT count(*) FROM "+quoteIdent(table)+" WHERE "+where, args...).Scan(&n)
+		return n, err
+	}
+
+	res, err := execLogged(context.Background(), tx, "DELETE FROM "+quoteIdent(table)+" WHERE "+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// checkPruneColumn fails unless table exists and has column. SQLite reads
+// a double-quoted name that matches no column as a string literal, so a
+// mistyped column would otherwise compare a constant against the cutoff.
+func checkPruneColumn(q querier, table, column string) error {
+	if ok, err := checkTable(q, table); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("no such table: %s", table)
+	}
+	cols, err := tableColumns(q, table)
+	if err != nil {
+		return err
+	}
+	if firstColumn(cols, []string{column}) == "" {
+		return fmt.Errorf("table %s has no column %q (columns: %s)", table, column, strings.Join(cols, ", "))
+	}
+	return nil
+}
+
+// gcRule expires rows of table whose column is older than maxAge.
+type gcRule struct {
+	table  string
+	column string
+	maxAge time.Duration
+}
+
+func newGCCmd() *cobra.Command {
+	var rules []string
+	var configPath string
+	var compact bool
+	var dryRun bool
+	var after afterMutation
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Delete expired rows according to retention rules",
+		Long: `Delete expired rows from every configured table, one transaction per table.
+
+Rules are given as --rule table:column:max-age (repeatable) or in a --config
+file with one "table column max-age" rule per line; blank lines and lines
+starting with # are ignored. Ages accept the same units as prune, e.g. 30d.
+
+--vacuum-after and --analyze-after run VACUUM and ANALYZE once every table
+has been pruned; neither runs with --dry-run.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var parsed []gcRule
+			if configPath != "" {
+				fromFile, err := readGCConfig(configPath)
+				if err != nil {
+					return err
+				}
+				parsed = append(parsed, fromFile...)
+			}
+			for _, r := range rules {
+				rule, err := parseGCRule(strings.Split(r, ":"))
+				if err != nil {
+					return fmt.Errorf("--rule %q: %w", r, err)
+				}
+				parsed = append(parsed, rule)
+			}
+			if len(parsed) == 0 {
+				return fmt.Errorf("no retention rules (use --rule or --config)")
+			}
+
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			// Check every rule first so a mistyped one fails before any
+			// table has been pruned.
+			for _, r := range parsed {
+				if err := checkPruneColumn(database, r.table, r.column); err != nil {
+					return fmt.Errorf("gc %s: %w", r.table, err)
+				}
+			}
+
+			now := time.Now()
+			var total int64
+			for _, r := range parsed {
+				n, err := pruneRows(database, r.table, r.column, now.Add(-r.maxAge), dryRun)
+				if err != nil {
+					return fmt.Errorf("gc %s: %w", r.table, err)
+				}
+				total += n
+				fmt.Printf("  %s: %d rows\n", r.table, n)
+			}
+
+			if dryRun {
+				fmt.Printf("Would delete %d rows\n", total)
+				return nil
+			}
+			fmt.Printf("Deleted %d rows\n", total)
+
+			if compact {
+				after.vacuum = true
+			}
+			return after.run(cmd.Context(), database)
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&rules, "rule", nil, "Retention rule table:column:max-age, e.g. env_backups:mtime:90d (repeatable)")
+	cmd.Flags().StringVar(&configPath, "config", "", "File of retention rules, one \"table column max-age\" per line")
+	cmd.Flags().BoolVar(&compact, "compact", false, "Run VACUUM after deleting")
+	cmd.Flags().MarkDeprecated("compact", "use --vacuum-after")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Count expired rows without deleting them")
+	after.addFlags(cmd)
+
+	return cmd
+}
+
+func parseGCRule(fields []string) (gcRule, error) {
+	if len(fields) != 3 {
+		return gcRule{}, fmt.Errorf("want table, column and max age")
+	}
+	age, err := parseAge(fields[2])
+	if err != nil {
+		return gcRule{}, err
+	}
+	return gcRule{table: fields[0], column: fields[1], maxAge: age}, nil
+}
+
+func readGCConfig(path string) ([]gcRule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var out []gcRule
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := parseGCRule(strings.Fields(line))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+		out = append(out, rule)
+	}
+	return out, nil
+}
@@ -0,0 +1,332 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+// readOnlyKeywords are the leading keywords query accepts without --write.
+var readOnlyKeywords = []string{"SELECT", "WITH", "EXPLAIN", "VALUES", "PRAGMA"}
+
+func newQueryCmd() *cobra.Command {
+	var asJSON bool
+	var asCSV bool
+	var write bool
+	var attachSpecs []string
+
+	cmd := &cobra.Command{
+		Use:   "query <sql>",
+		Short: "Run a SQL statement and print the results",
+		Long: `Run a single SQL statement and print its results as an aligned table.
+
+Pass "-" to read the statement from stdin. Only read-only statements are
+accepted unless --write is given; read-only statements run with writes
+disabled at the connection level.
+
+--attach name=path (repeatable) attaches another database file under name
+before the statement runs, so it can join across files, e.g.
+SELECT ... FROM main.sessions JOIN staging.sessions USING (id). Each file
+must exist and be a SQLite database; it is detached again afterwards.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if asJSON && asCSV {
+				return fmt.Errorf("--json and --csv are mutually exclusive")
+			}
+
+			stmt := args[0]
+			if stmt == "-" {
+				b, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return err
+				}
+				stmt = string(b)
+			}
+			stmt = strings.TrimSpace(stmt)
+			if stmt == "" {
+				return fmt.Errorf("empty statement")
+			}
+			readOnly := isReadOnlyStatement(stmt)
+			if !readOnly && !write {
+				return fmt.Errorf("statement is not read-only; pass --write to run %q", leadingKeyword(stmt))
+			}
+			attachments, err := parseAttachments(attachSpecs)
+			if err != nil {
+				return err
+			}
+
+			var database *sql.DB
+			if write {
+				database, err = openDB(dbPath())
+			} else {
+				database, err = openReadOnly(dbPath(), false)
+			}
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			// ATTACH is per connection, so everything runs on one.
+			ctx := cmd.Context()
+			conn, err := database.Conn(ctx)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			detach, err := attachDatabases(ctx, conn, attachments)
+			if err != nil {
+				return err
+			}
+			defer detach()
+
+			var q querier = ctxQuerier{ctx: ctx, q: conn}
+			if !write {
+				tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+				if err != nil {
+					return err
+				}
+				defer tx.Rollback()
+				if _, err := tx.Exec("PRAGMA query_only = ON"); err != nil {
+					return err
+				}
+				defer tx.Exec("PRAGMA query_only = OFF")
+				q = tx
+			} else if !readOnly {
+				res, err := conn.ExecContext(ctx, stmt)
+				if err != nil {
+					return err
+				}
+				n, _ := res.RowsAffected()
+				fmt.Printf("%d row(s) affected\n", n)
+				return nil
+			}
+
+			rows, err := q.Query(stmt)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			switch {
+			case asJSON:
+				return printRowsJSON(rows)
+			case asCSV:
+				return printRowsCSV(rows)
+			default:
+				return printRowsTable(rows)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print results as a JSON array of objects")
+	cmd.Flags().BoolVar(&asCSV, "csv", false, "Print results as CSV with a header row")
+	cmd.Flags().BoolVar(&write, "write", false, "Allow statements that modify the database")
+	cmd.Flags().StringArrayVar(&attachSpecs, "attach", nil, "Attach another database as name=path (repeatable)")
+
+	return cmd
+}
+
+// attachment is one --attach name=path.
+type attachment struct {
+	name string
+	path string
+}
+
+var attachNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseAttachments parses --attach values and checks that each file exists
+// and is a SQLite database, since ATTACH would otherwise create it.
+func parseAttachments(specs []string) ([]attachment, error) {
+	var out []attachment
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		name, path, ok := strings.Cut(spec, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("--attach %q: want name=path", spec)
+		}
+		if !attachNameRe.MatchString(name) {
+			return nil, fmt.Errorf("--attach %q: invalid schema name %q", spec, name)
+		}
+		lower := strings.ToLower(name)
+		if lower == "main" || lower == "temp" {
+			return nil, fmt.Errorf("--attach %q: %s is reserved", spec, name)
+		}
+		if seen[lower] {
+			return nil, fmt.Errorf("--attach %q: %s is attached twice", spec, name)
+		}
+		seen[lower] = true
+		if err := validateSQLiteHeader(path); err != nil {
+			return nil, fmt.Errorf("--attach %q: %w", spec, err)
+		}
+		out = append(out, attachment{name: name, path: path})
+	}
+	return out, nil
+}
+
+// attachDatabases attaches each database to conn. The returned func detaches
+// them again, so the connection goes back to the pool as it came out.
+func attachDatabases(ctx context.Context, conn *sql.Conn, attachments []attachment) (func(), error) {
+	var done []string
+	detach := func() {
+		for _, name := range done {
+			if _, err := conn.ExecContext(ctx, "DETACH DATABASE "+quoteIdent(name)); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: detach %s: %v\n", name, err)
+			}
+		}
+	}
+	for _, a := range attachments {
+		if _, err := execLogged(ctx, conn, "ATTACH DATABASE ? AS "+quoteIdent(a.name), a.path); err != nil {
+			detach()
+			return nil, fmt.Errorf("attach %s: %w", a.path, err)
+		}
+		done = append(done, a.name)
+	}
+	return detach, nil
+}
+
+func leadingKeyword(stmt string) string {
+	for _, line := range strings.Split(stmt, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+		fields := strings.Fields(line)
+		return strings.ToUpper(strings.TrimLeft(fields[0], "("))
+	}
+	return ""
+}
+
+func isReadOnlyStatement(stmt string) bool {
+	return containsString(readOnlyKeywords, leadingKeyword(stmt))
+}
+
+func scanRow(rows *sql.Rows, n int) ([]any, error) {
+	vals := make([]any, n)
+	ptrs := make([]any, n)
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+func printRowsTable(rows *sql.Rows) error {
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(cols, "\t")))
+	n := 0
+	for rows.Next() {
+		vals, err := scanRow(rows, len(cols))
+		if err != nil {
+			return err
+		}
+		fields := make([]string, len(vals))
+		for i, v := range vals {
+			fields[i] = displayValue(v)
+		}
+		fmt.Fprintln(tw, strings.Join(fields, "\t"))
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("(%d rows)\n", n)
+	return nil
+}
+
+// displayValue renders a value for the aligned table view. Binary data is
+// shown as a hex literal since raw bytes would corrupt the alignment.
+func displayValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		if !utf8.Valid(v) {
+			return sqlLiteral(v)
+		}
+	}
+	return strings.NewReplacer("\t", " ", "\n", " ").Replace(csvValue(v))
+}
+
+func printRowsCSV(rows *sql.Rows) error {
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write(cols); err != nil {
+		return err
+	}
+	record := make([]string, len(cols))
+	for rows.Next() {
+		vals, err := scanRow(rows, len(cols))
+		if err != nil {
+			return err
+		}
+		for i, v := range vals {
+			record[i] = csvValue(v)
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func printRowsJSON(rows *sql.Rows) error {
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+
+	out := []map[string]any{}
+	for rows.Next() {
+		vals, err := scanRow(rows, len(cols))
+		if err != nil {
+			return err
+		}
+		row := map[string]any{}
+		for i, c := range cols {
+			row[c] = jsonValue(vals[i], types[i].DatabaseTypeName())
+		}
+		out = append(out, row)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
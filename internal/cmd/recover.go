@@ -0,0 +1,124 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newRecoverCmd() *cobra.Command {
+	var outPath string
+	var force bool
+	var sqlite3Bin string
+
+	cmd := &cobra.Command{
+		Use:   "recover",
+		Short: "Salvage data from a corrupt database into a new file",
+		Long: `Read whatever can be salvaged from a corrupt database and write it to a fresh
+database at --out, as the sqlite3 shell's .recover command does. Rows whose
+table cannot be identified are put in a lost_and_found table.
+
+The SQLite recovery API is not part of the Go driver, so this runs the
+sqlite3 command-line shell (from PATH, or --sqlite3) and fails if it is not
+installed. The source file is only read. The output is integrity checked and
+the number of rows recovered per table is printed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if outPath == "" {
+				return fmt.Errorf("--out is required")
+			}
+			bin, err := exec.LookPath(sqlite3Bin)
+			if err != nil {
+				return fmt.Errorf("recover needs the sqlite3 shell, which was not found (install sqlite3 or pass --sqlite3): %w", err)
+			}
+			path := dbPath()
+			if _, err := os.Stat(path); err != nil {
+				return err
+			}
+			if _, err := os.Stat(outPath); err == nil {
+				if !force {
+					return fmt.Errorf("%s already exists (use --force to overwrite)", outPath)
+				}
+				for _, p := range []string{outPath, outPath + "-wal", outPath + "-shm"} {
+					if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+						return err
+					}
+				}
+			}
+
+			if err := runRecover(bin, path, outPath); err != nil {
+				os.Remove(outPath)
+				return err
+			}
+
+			database, err := openReadOnly(outPath, false)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			problems, err := integrityProblems(database, "integrity_check")
+			if err != nil {
+				return fmt.Errorf("check %s: %w", outPath, err)
+			}
+			tables, err := listTables(database)
+			if err != nil {
+				return err
+			}
+			total := 0
+			for _, t := range tables {
+				n, err := countRows(database, quoteIdent(t))
+				if err != nil {
+					fmt.Printf("  %-20s ? (%v)\n", t+":", err)
+					continue
+				}
+				total += n
+				fmt.Printf("  %-20s %d rows\n", t+":", n)
+			}
+			fmt.Printf("Recovered %d rows in %d tables from %s to %s\n", total, len(tables), path, outPath)
+			if len(problems) > 0 {
+				return fmt.Errorf("recovered database failed integrity_check: %s", strings.Join(problems, "; "))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&outPath, "out", "", "File to write the recovered database to")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite the destination if it exists")
+	cmd.Flags().StringVar(&sqlite3Bin, "sqlite3", "sqlite3", "sqlite3 shell to run .recover with")
+
+	return cmd
+}
+
+// runRecover pipes the SQL that "sqlite3 src .recover" prints into a second
+// sqlite3 process that builds dst.
+func runRecover(bin, src, dst string) error {
+	dump := exec.Command(bin, "-readonly", src, ".recover")
+	load := exec.Command(bin, "-bail", dst)
+	var dumpErr, loadErr bytes.Buffer
+	dump.Stderr = &dumpErr
+	load.Stderr = &loadErr
+
+	pipe, err := dump.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	load.Stdin = pipe
+	if err := load.Start(); err != nil {
+		return err
+	}
+	if err := dump.Run(); err != nil {
+		load.Wait()
+		return fmt.Errorf(".recover: %w: %s", err, strings.TrimSpace(dumpErr.String()))
+	}
+	if err := load.Wait(); err != nil {
+		return fmt.Errorf("load recovered SQL: %w: %s", err, strings.TrimSpace(loadErr.String()))
+	}
+	return nil
+}
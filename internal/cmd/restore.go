@@ -0,0 +1,181 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// sqliteMagic is the 16-byte header every SQLite database file starts with.
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+func newRestoreCmd() *cobra.Command {
+	var from string
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Replace the live database with a backup",
+		Long: `Replace the live database with a backup file.
+
+The source must be a valid SQLite database that passes PRAGMA integrity_check.
+The current database is checkpointed and copied to <path>.bak, then the backup
+is written to a temporary file next to it and renamed into place.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if from == "" {
+				return fmt.Errorf("--from is required")
+			}
+
+			if err := validateSQLiteFile(from); err != nil {
+				return fmt.Errorf("invalid backup %s: %w", from, err)
+			}
+
+			path := dbPath()
+			if !yes {
+				fmt.Printf("This will replace %s with %s.\n", path, from)
+				return fmt.Errorf("refusing to restore without --yes")
+			}
+
+			if err := replaceDatabase(path, from); err != nil {
+				return err
+			}
+
+			fmt.Printf("Restored %s from %s\n", path, from)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "", "Backup file to restore from")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm replacing the live database")
+
+	return cmd
+}
+
+// replaceDatabase swaps the database at path for a copy of from. The
+// current database is checkpointed and saved to <path>.bak first, and the
+// copy is renamed into place so the swap is atomic.
+func replaceDatabase(path, from string) error {
+	if _, err := os.Stat(path); err == nil {
+		if err := checkpointLive(path); err != nil {
+			return err
+		}
+		if err := copyFile(path, path+".bak"); err != nil {
+			return fmt.Errorf("backup current database: %w", err)
+		}
+		fmt.Printf("Saved current database to %s.bak\n", path)
+	} else if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp := path + ".restore-tmp"
+	if err := copyFile(from, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// Stale WAL/SHM files belong to the old database and must not be
+	// replayed against the restored one.
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(path + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSQLiteFile checks the magic header and runs an integrity check on
+// path without modifying it.
+func validateSQLiteFile(path string) error {
+	if err := validateSQLiteHeader(path); err != nil {
+		return err
+	}
+
+	src, err := sql.Open("sqlite", readOnlyDSN(path, false))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	problems, err := integrityProblems(src, "integrity_check")
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateSQLiteHeader reports whether path starts with the SQLite magic
+// header.
+func validateSQLiteHeader(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header := make([]byte, len(sqliteMagic))
+	if _, err := io.ReadFull(f, header); err != nil || !bytes.Equal(header, sqliteMagic) {
+		return fmt.Errorf("not a SQLite database")
+	}
+	return nil
+}
+
+// checkpointLive folds the WAL into the main database file so that copying
+// the file captures every committed transaction. It fails if the checkpoint
+// could not finish, since the copy would then miss the transactions still in
+// the WAL. The connection is closed before returning so this process holds
+// nothing open during the swap.
+func checkpointLive(path string) error {
+	database, err := openDB(path)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	res, err := walCheckpoint(context.Background(), database, "TRUNCATE")
+	if err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	if res.busy {
+		return fmt.Errorf("checkpoint of %s did not complete because another connection is using it; close other connections and retry", path)
+	}
+	return database.Close()
+}
+
+// copyFile copies src to dst, syncing dst before it is closed.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
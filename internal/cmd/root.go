@@ -4,11 +4,17 @@
 package cmd
 
 import (
+	"bufio"
+	"compress/gzip"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -18,61 +24,224 @@ import (
 	"github.com/yourorg/arc-sdk/db/migrations"
 )
 
+// dbPathFlag holds the value of the persistent --db flag.
+var dbPathFlag string
+
+// resolveDBPath returns the database path chosen by --db, then ARC_DB_PATH,
+// then db.DefaultDBPath(), along with where it came from.
+func resolveDBPath() (path, source string) {
+	if dbPathFlag != "" {
+		return dbPathFlag, "--db"
+	}
+	if p := os.Getenv("ARC_DB_PATH"); p != "" {
+		return p, "ARC_DB_PATH"
+	}
+	return db.DefaultDBPath(), "default"
+}
+
+func dbPath() string {
+	path, _ := resolveDBPath()
+	return path
+}
+
+// exitError makes the process exit with code instead of 1, for commands
+// whose exit status is part of their interface.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// ExitCode returns the process exit status for an error returned by the
+// root command: 0 for nil, the code of an exitError, and 1 otherwise.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	return 1
+}
+
 // NewRootCmd creates the root command for arc-db.
 func NewRootCmd() *cobra.Command {
 	root := &cobra.Command{
 		Use:   "arc-db",
 		Short: "Database operations",
 		Long:  `Database operations including info, migrations, vacuum, and export.`,
-		RunE:  func(cmd *cobra.Command, args []string) error { return cmd.Help() },
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if verboseFlag {
+				stmtLogger = stderrLogger{}
+			}
+			if traceFlag != "" {
+				// Spans are written unbuffered, so the file needs no close.
+				f, err := os.OpenFile(traceFlag, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+				if err != nil {
+					return fmt.Errorf("--trace: %w", err)
+				}
+				activeTracer = newJSONTracer(f)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error { return cmd.Help() },
 	}
 
+	root.PersistentFlags().StringVar(&dbPathFlag, "db", "", "Database file to use (default: $ARC_DB_PATH, then the arc data dir)")
+	root.PersistentFlags().DurationVar(&busyTimeoutFlag, "busy-timeout", busyTimeoutFlag, "How long to wait for a locked database before failing")
+	root.PersistentFlags().BoolVar(&walFlag, "wal", false, "Switch the database to write-ahead logging")
+	root.PersistentFlags().BoolVar(&noWALFlag, "no-wal", false, "Switch the database to a rollback journal instead of write-ahead logging")
+	root.PersistentFlags().BoolVar(&noForeignKeysFlag, "no-foreign-keys", false, "Do not enforce foreign keys (for bulk loads)")
+	root.MarkFlagsMutuallyExclusive("wal", "no-wal")
+	root.PersistentFlags().StringVar(&traceFlag, "trace", "", "Append JSON trace spans for migrations, vacuum, export and their SQL to this file")
+	root.PersistentFlags().BoolVarP(&verboseFlag, "verbose", "v", false, "Log SQL run by migrations and maintenance commands to stderr, and show error details")
+
 	root.AddCommand(newInfoCmd())
+	root.AddCommand(newStatsCmd())
+	root.AddCommand(newCountCmd())
+	root.AddCommand(newSizeCmd())
 	root.AddCommand(newMigrateCmd())
+	root.AddCommand(newUserVersionCmd())
 	root.AddCommand(newVacuumCmd())
+	root.AddCommand(newCheckpointCmd())
+	root.AddCommand(newAnalyzeCmd())
+	root.AddCommand(newReindexCmd())
+	root.AddCommand(newMaintenanceCmd())
+	root.AddCommand(newPruneCmd())
+	root.AddCommand(newGCCmd())
+	root.AddCommand(newDepsCmd())
+	root.AddCommand(newExternalReposCmd())
+	root.AddCommand(newBackupCmd())
+	root.AddCommand(newRestoreCmd())
+	root.AddCommand(newCloneCmd())
+	root.AddCommand(newSnapshotCmd())
+	root.AddCommand(newCheckCmd())
+	root.AddCommand(newDoctorCmd())
+	root.AddCommand(newHealthcheckCmd())
+	root.AddCommand(newRecoverCmd())
+	root.AddCommand(newSchemaCmd())
+	root.AddCommand(newDiffCmd())
+	root.AddCommand(newAlterCmd())
 	root.AddCommand(newExportCmd())
+	root.AddCommand(newImportCmd())
+	root.AddCommand(newSeedCmd())
+	root.AddCommand(newEnvBackupsCmd())
+	root.AddCommand(newQueryCmd())
+	root.AddCommand(newShellCmd())
+	root.AddCommand(newTailCmd())
 	root.AddCommand(newPathCmd())
 
 	return root
 }
 
 func newInfoCmd() *cobra.Command {
-	return &cobra.Command{
+	var asJSON bool
+	var sizes bool
+	var format string
+
+	cmd := &cobra.Command{
 		Use:   "info",
 		Short: "Show database info and table counts",
+		Long: `Show the database path, SQLite version, file size and per-table row counts.
+
+--format prometheus writes the same data in the Prometheus text exposition
+format for a node_exporter textfile collector. The metric names are stable:
+
+  arc_db_table_rows{table="..."}         rows in each table
+  arc_db_table_count_errors{table="..."} 1 for each table that could not be counted
+  arc_db_file_bytes                      size of the database file
+  arc_db_sqlite_version_info{version}    always 1, labelled with the SQLite version
+  arc_db_table_data_bytes{table="..."}   with --sizes, bytes of table data
+  arc_db_table_index_bytes{table="..."}  with --sizes, bytes of the table's indexes`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			path := db.DefaultDBPath()
-			database, err := db.Open(path)
+			if asJSON {
+				format = "json"
+			}
+			switch format {
+			case "text", "json", "prometheus":
+			default:
+				return fmt.Errorf("unsupported format %q (want text, json or prometheus)", format)
+			}
+			path := dbPath()
+			database, err := openReadOnly(path, false)
 			if err != nil {
 				return err
 			}
 			defer database.Close()
 
-			fmt.Printf("DB path: %s\n", path)
+			info, err := collectInfo(database, path)
+			if err != nil {
+				return err
+			}
+
+			noDBStat := false
+			if sizes {
+				info.Sizes, err = tableSizes(database)
+				if errors.Is(err, errNoDBStat) {
+					fmt.Fprintf(os.Stderr, "warning: %v; only the total file size is available\n", err)
+					noDBStat = true
+				} else if err != nil {
+					return err
+				}
+			}
+
+			switch format {
+			case "json":
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(info)
+			case "prometheus":
+				return writePrometheus(os.Stdout, info)
+			}
+
+			_, source := resolveDBPath()
+			fmt.Printf("DB path: %s (%s)\n", info.DBPath, source)
+			if info.SQLiteVersion != "" {
+				fmt.Printf("SQLite version: %s\n", info.SQLiteVersion)
+			}
+			fmt.Printf("File size: %d bytes\n", info.FileSizeBytes)
 
-			var ver string
-			if err := database.QueryRow("SELECT sqlite_version();").Scan(&ver); err == nil {
-				fmt.Printf("SQLite version: %s\n", ver)
+			width := 20
+			for _, tbl := range info.tableNames {
+				width = max(width, len(tbl)+1)
 			}
 
 			fmt.Println()
-			showCount := func(tbl string) {
-				var cnt int
-				err := database.QueryRow(fmt.Sprintf("SELECT count(*) FROM %s", tbl)).Scan(&cnt)
-				if err == nil {
-					fmt.Printf("%-20s %d\n", tbl+":", cnt)
+			for _, tbl := range info.tableNames {
+				if cnt, ok := info.Tables[tbl]; ok {
+					fmt.Printf("%-*s %d\n", width, tbl+":", cnt)
+				} else if verboseFlag {
+					fmt.Printf("%-*s ? (%s)\n", width, tbl+":", info.CountErrors[tbl])
+				} else {
+					fmt.Printf("%-*s ?\n", width, tbl+":")
 				}
 			}
 
-			showCount("schema_migrations")
-			showCount("sessions")
-			showCount("external_repos")
-			showCount("env_backups")
-			showCount("repo_dependencies")
+			if sizes && !noDBStat {
+				fmt.Println()
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "TABLE\tDATA\tINDEXES\tTOTAL")
+				for _, ts := range info.Sizes {
+					fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", ts.Table, ts.DataBytes, ts.IndexBytes, ts.DataBytes+ts.IndexBytes)
+				}
+				w.Flush()
+			}
 
+			if n := len(info.CountErrors); n > 0 && !verboseFlag {
+				fmt.Fprintf(os.Stderr, "warning: %d table(s) could not be counted; rerun with --verbose for the errors\n", n)
+			}
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print info as a JSON object (same as --format json)")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text, json or prometheus")
+	cmd.Flags().BoolVar(&sizes, "sizes", false, "Report on-disk bytes per table using dbstat (scans the whole file)")
+
+	return cmd
 }
 
 func newMigrateCmd() *cobra.Command {
@@ -83,31 +252,92 @@ func newMigrateCmd() *cobra.Command {
 	}
 
 	var pretty bool
+	var asJSON bool
+	var pendingOnly bool
+	var statusDir string
 	statusCmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show applied and available migrations",
+		Long: `Show applied and available migrations.
+
+With --pending, only migrations that are not yet applied are listed and the
+command exits non-zero when there are any, so scripts can branch on it.
+migrate check also tells pending from unknown migrations by exit code.
+
+With --migrations-dir, the migrations in that directory are listed instead of
+the embedded ones.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			path := db.DefaultDBPath()
-			database, err := db.Open(path)
+			path := dbPath()
+			database, err := openDB(path)
 			if err != nil {
 				return err
 			}
 			defer database.Close()
 
-			fmt.Printf("DB path: %s\n\n", path)
-
-			avail, _ := migrations.Embedded()
+			avail, _, err := loadMigrations(statusDir)
+			if err != nil {
+				return err
+			}
 			applied, _ := migrations.Applied(database)
+			appliedAt, err := migrationAppliedAt(database)
+			if err != nil {
+				return err
+			}
+
+			if pendingOnly {
+				pending := pendingMigrations(avail, applied)
+				if asJSON {
+					out := make([]migrationStatus, 0, len(pending))
+					for _, m := range pending {
+						out = append(out, migrationStatus{Version: m.Version, Name: m.Name})
+					}
+					enc := json.NewEncoder(os.Stdout)
+					enc.SetIndent("", "  ")
+					if err := enc.Encode(out); err != nil {
+						return err
+					}
+				} else if len(pending) == 0 {
+					fmt.Println("No pending migrations.")
+				} else {
+					for _, m := range pending {
+						fmt.Printf("  %03d %s\n", m.Version, m.Name)
+					}
+				}
+				if len(pending) > 0 {
+					return fmt.Errorf("%d pending migration(s)", len(pending))
+				}
+				return nil
+			}
+
+			if asJSON {
+				out := make([]migrationStatus, 0, len(avail))
+				for _, m := range avail {
+					_, ok := applied[m.Version]
+					out = append(out, migrationStatus{
+						Version:   m.Version,
+						Name:      m.Name,
+						Applied:   ok,
+						AppliedAt: appliedAt[m.Version],
+					})
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(out)
+			}
+
+			fmt.Printf("DB path: %s\n\n", path)
 
 			if pretty {
 				tw := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
-				fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+				fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED\tAPPLIED AT")
 				for _, m := range avail {
 					appliedStr := "no"
+					atStr := ""
 					if _, ok := applied[m.Version]; ok {
 						appliedStr = "yes"
+						atStr = appliedAtOrDash(appliedAt, m.Version)
 					}
-					fmt.Fprintf(tw, "%03d\t%s\t%s\n", m.Version, m.Name, appliedStr)
+					fmt.Fprintf(tw, "%03d\t%s\t%s\t%s\n", m.Version, m.Name, appliedStr, atStr)
 				}
 				return tw.Flush()
 			}
@@ -122,7 +352,7 @@ func newMigrateCmd() *cobra.Command {
 			}
 			sort.Ints(keys)
 			for _, v := range keys {
-				fmt.Printf("  %03d %s\n", v, applied[v])
+				fmt.Printf("  %03d %-30s %s\n", v, applied[v], appliedAtOrDash(appliedAt, v))
 			}
 
 			fmt.Println("\nAvailable:")
@@ -137,92 +367,842 @@ func newMigrateCmd() *cobra.Command {
 		},
 	}
 	statusCmd.Flags().BoolVar(&pretty, "pretty", false, "Show migrations in a formatted table")
+	statusCmd.Flags().BoolVar(&asJSON, "json", false, "Print migrations as a JSON array")
+	statusCmd.Flags().BoolVar(&pendingOnly, "pending", false, "Only list unapplied migrations; exit non-zero if there are any")
+	statusCmd.Flags().StringVar(&statusDir, "migrations-dir", "", "Read migrations from this directory instead of the embedded set")
 	mc.AddCommand(statusCmd)
 
-	mc.AddCommand(&cobra.Command{
+	var lockTimeout time.Duration
+	var quiet bool
+	var upDir string
+	var dryRun bool
+	upCmd := &cobra.Command{
 		Use:   "up",
 		Short: "Apply pending migrations",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			database, err := db.Open(db.DefaultDBPath())
+		Long: `Apply pending embedded migrations.
+
+Embedded migrations are applied one at a time, like --migrations-dir, when
+their SQL is found in ` + defaultMigrationsDir + `. arc-sdk does not expose the
+embedded SQL, so without that copy the arc-sdk migrator applies them in a
+single call: they then get no transactions of their own, no
+` + noTransactionDirective + ` handling, no statement-level errors or timings, and
+no checksum for migrate verify. A warning on stderr says when that happens.
+
+With --migrations-dir, the migrations in that directory are applied instead,
+each in its own transaction, so edited SQL can be tried without rebuilding.
+A failing statement is reported by number with its SQL.
+
+With --dry-run, each pending migration's version, name and SQL is printed and
+the SQL is run inside a transaction that is rolled back, so errors surface
+without changing the database. SQL for embedded migrations is read from
+--migrations-dir, or from ` + defaultMigrationsDir + ` when that exists.`,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			end := traceCommand(cmd)
+			defer func() { end(err) }()
+
+			database, err := openDBContext(cmd.Context(), dbPath())
 			if err != nil {
 				return err
 			}
 			defer database.Close()
 
-			if err := migrations.RunMigrations(database); err != nil {
+			unlock, err := acquireMigrationLock(cmd.Context(), database, lockTimeout)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+
+			avail, sources, err := loadMigrations(upDir)
+			if err != nil {
+				return err
+			}
+			if upDir != "" {
+				if err := ensureMigrationsTable(cmd.Context(), database); err != nil {
+					return err
+				}
+			}
+			applied, err := migrations.Applied(database)
+			if err != nil {
+				return err
+			}
+			pending := pendingMigrations(avail, applied)
+			if len(pending) == 0 {
+				fmt.Println("No pending migrations.")
+				return nil
+			}
+			if upDir == "" {
+				sources = embeddedSources(defaultMigrationsDir, pending)
+			}
+
+			if dryRun {
+				if sources == nil {
+					// Best effort: the embedded SQL itself is not exposed.
+					sources, _ = readMigrationSources(defaultMigrationsDir)
+				}
+				return dryRunMigrations(cmd.Context(), database, pending, sources)
+			}
+
+			// RunMigrations takes no context; stop before it if interrupted.
+			if err := cmd.Context().Err(); err != nil {
 				return err
 			}
-			fmt.Println("Migrations applied (if any).")
+			start := time.Now()
+			if sources != nil {
+				for _, m := range pending {
+					stepStart := time.Now()
+					if err := applyMigration(cmd.Context(), database, sources[m.Version]); err != nil {
+						return err
+					}
+					if !quiet {
+						fmt.Printf("  applied %03d %s in %dms\n", m.Version, m.Name, time.Since(stepStart).Milliseconds())
+					}
+				}
+			} else {
+				fmt.Fprintln(os.Stderr, embeddedMigratorWarning)
+				if err := migrations.RunMigrations(database); err != nil {
+					return err
+				}
+				// The embedded migrator applies the batch in one call, so
+				// only the total duration is measurable.
+				if !quiet {
+					for _, m := range pending {
+						fmt.Printf("  applied %03d %s\n", m.Version, m.Name)
+					}
+				}
+			}
+			elapsed := time.Since(start)
+			fmt.Printf("Applied %d migration(s) in %dms.\n", len(pending), elapsed.Milliseconds())
 			return nil
 		},
-	})
+	}
+	upCmd.Flags().DurationVar(&lockTimeout, "lock-timeout", 30*time.Second, "How long to wait for another migration run to finish (0 fails immediately)")
+	upCmd.Flags().BoolVar(&quiet, "quiet", false, "Only print the summary line")
+	upCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print pending migrations and check their SQL without applying them")
+	upCmd.Flags().StringVar(&upDir, "migrations-dir", "", "Apply migrations from this directory instead of the embedded set")
+	mc.AddCommand(upCmd)
+
+	mc.AddCommand(newMigrateCheckCmd())
+	mc.AddCommand(newMigrateToCmd())
+	mc.AddCommand(newMigrateRedoCmd())
+	mc.AddCommand(newMigrateCreateCmd())
+	mc.AddCommand(newMigrateVerifyCmd())
+	mc.AddCommand(newMigrateForceCmd())
+	mc.AddCommand(newMigrateUnlockCmd())
 
 	return mc
 }
 
 func newVacuumCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "vacuum",
+	var into string
+	var force bool
+	var incremental int
+	var timeout time.Duration
+	var wait bool
+
+	cmd := &cobra.Command{
+		Use:   "vacuum [pages]",
 		Short: "Run VACUUM on the database",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			path := db.DefaultDBPath()
-			database, err := db.Open(path)
+		Long: `Run VACUUM on the database to rebuild and compact it in place.
+
+With --into, VACUUM INTO writes a compacted copy to a new file and leaves the
+live database untouched, which also makes a consistent backup.
+
+With --incremental, PRAGMA incremental_vacuum frees up to N pages (all free
+pages when no count is given) without rewriting the file. This only works on
+databases created with auto_vacuum=INCREMENTAL.
+
+With --timeout, vacuum gives up if it cannot get the write lock in time and
+lists the processes that have the database open, where the OS exposes them.
+--wait prints a dot to stderr while it waits.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			end := traceCommand(cmd)
+			defer func() { end(err) }()
+
+			path := dbPath()
+			if into != "" && cmd.Flags().Changed("incremental") {
+				return fmt.Errorf("--into and --incremental are mutually exclusive")
+			}
+			// "--incremental 100" leaves the count as a positional argument
+			// because the flag's value is optional.
+			if len(args) == 1 {
+				if !cmd.Flags().Changed("incremental") {
+					return fmt.Errorf("unexpected argument %q", args[0])
+				}
+				n, err := strconv.Atoi(args[0])
+				if err != nil {
+					return fmt.Errorf("invalid page count %q", args[0])
+				}
+				incremental = n
+			}
+
+			if into != "" {
+				if _, err := os.Stat(into); err == nil {
+					if !force {
+						return fmt.Errorf("%s already exists (use --force to overwrite)", into)
+					}
+					if err := os.Remove(into); err != nil {
+						return err
+					}
+				}
+			}
+
+			if timeout < 0 {
+				return fmt.Errorf("--timeout must not be negative")
+			}
+			if wait && timeout == 0 {
+				return fmt.Errorf("--wait requires --timeout")
+			}
+
+			database, err := openDBContext(cmd.Context(), path)
 			if err != nil {
 				return err
 			}
 			defer database.Close()
 
-			if _, err := database.Exec("VACUUM"); err != nil {
+			// VACUUM INTO only reads, so only in-place vacuums wait for the
+			// write lock.
+			if into == "" {
+				conn, err := database.Conn(cmd.Context())
+				if err != nil {
+					return err
+				}
+				defer conn.Close()
+				var tick func()
+				if wait {
+					tick = func() { fmt.Fprint(os.Stderr, ".") }
+				}
+				if timeout > 0 {
+					err := waitForWriteLock(cmd.Context(), conn, timeout, tick)
+					if wait {
+						fmt.Fprintln(os.Stderr)
+					}
+					if err != nil {
+						return lockTimeoutError(path, timeout, err)
+					}
+				}
+
+				if cmd.Flags().Changed("incremental") {
+					err = incrementalVacuum(cmd.Context(), conn, incremental)
+				} else {
+					_, err = execLogged(cmd.Context(), conn, "VACUUM")
+				}
+				if err != nil {
+					if timeout > 0 {
+						return lockTimeoutError(path, timeout, err)
+					}
+					return err
+				}
+				if !cmd.Flags().Changed("incremental") {
+					fmt.Printf("VACUUM completed for %s\n", path)
+				}
+				return nil
+			}
+
+			if _, err := execLogged(cmd.Context(), database, "VACUUM INTO ?", into); err != nil {
 				return err
 			}
-			fmt.Printf("VACUUM completed for %s\n", path)
+			fmt.Printf("VACUUM INTO completed: %s\n", into)
+			if src, err := os.Stat(path); err == nil {
+				if dst, err := os.Stat(into); err == nil {
+					fmt.Printf("Size: %d bytes (source %d bytes, saved %d bytes)\n", dst.Size(), src.Size(), src.Size()-dst.Size())
+				}
+			}
 			return nil
 		},
 	}
+
+	cmd.Flags().StringVar(&into, "into", "", "Write a compacted copy to this path instead of vacuuming in place")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite the --into target if it exists")
+	cmd.Flags().IntVar(&incremental, "incremental", 0, "Run PRAGMA incremental_vacuum, freeing up to N pages (0 or no value frees all)")
+	cmd.Flags().Lookup("incremental").NoOptDefVal = "0"
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Fail if the write lock cannot be acquired within this long (0 uses --busy-timeout)")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Print progress dots while waiting for the write lock")
+
+	return cmd
+}
+
+// incrementalVacuum frees up to pages free-list pages (all when 0) and
+// reports the free-list size before and after.
+func incrementalVacuum(ctx context.Context, database *sql.Conn, pages int) error {
+	if pages < 0 {
+		return fmt.Errorf("--incremental must not be negative")
+	}
+	var mode int
+	if err := database.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return err
+	}
+	// auto_vacuum: 0 = none, 1 = full, 2 = incremental.
+	if mode != 2 {
+		fmt.Fprintln(os.Stderr, "warning: auto_vacuum is not INCREMENTAL; incremental_vacuum does nothing on this database")
+	}
+
+	var before, after int
+	if err := database.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&before); err != nil {
+		return err
+	}
+	// The pragma frees one page per step, so it must be stepped to completion
+	// rather than executed once.
+	stmt := fmt.Sprintf("PRAGMA incremental_vacuum(%d)", pages)
+	done := startStatement(ctx, stmt)
+	rows, err := database.QueryContext(ctx, stmt)
+	if err != nil {
+		done(err)
+		return err
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	done(rows.Err())
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := database.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&after); err != nil {
+		return err
+	}
+	fmt.Printf("Free pages: %d -> %d (freed %d)\n", before, after, before-after)
+	return nil
 }
 
 func newExportCmd() *cobra.Command {
 	var tablesCSV string
 	var outPath string
+	var format string
+	var gzipOut bool
+	var compress string
+	var columnsSpec string
+	var where string
+	var sinceColumn, sinceValue string
+	var header bool
+	var legacyTS bool
+	var raw bool
+	var indent bool
+	var withSchema bool
+	var blob string
+	var keys envelopeKeys
+	var skipEmpty, onlyEmpty bool
+	var continueOnError bool
+	var outDir string
+	var bufSize int
+	var flushEvery int
+	var immutable bool
+	var quiet bool
+	var concurrency int
+	var orderSpec string
+	var redact []string
+	var dropColumns []string
+	var outURL string
+	var outHeaders []string
+	var outS3 string
+	var ignoreMissing bool
+	var limit, offset int
+	var excludeCSV string
+	var includeMigrations bool
 
 	cmd := &cobra.Command{
 		Use:   "export",
-		Short: "Export tables to JSONL",
-		Long:  `Export database tables to JSONL format (one JSON object per line).`,
-		RunE: func(cmd *cobra.Command, args []string) error {
-			database, err := db.Open(db.DefaultDBPath())
+		Short: "Export tables to JSONL, JSON, CSV or SQL",
+		Long: `Export database tables to JSONL format (one JSON object per line).
+
+Every row object carries the same "ts": the time the export started. With
+--header, that time is written once in a leading header object instead;
+--legacy-ts restores the old per-row timestamps. --raw drops the envelope and
+writes each row as a top-level object; it needs a single table or --out-dir.
+--key-table, --key-row and --key-ts rename the envelope fields, e.g. to
+_table, _data and _exported_at; import only reads the default names.
+
+Every table named in --tables must exist; a typo fails with a suggestion
+unless --ignore-missing is given, which skips missing tables. Without
+--tables, every table in the database is exported except the
+schema_migrations bookkeeping tables, and --exclude drops the named tables
+from that set; each must exist. --include-schema-migrations adds
+schema_migrations to the default set so that import can restore a clone's
+migration state.
+
+With --out-dir, each table is written to its own <table>.<format> file in
+that directory. --concurrency N exports up to N tables at once; each is then
+read in its own transaction, so tables may come from different snapshots. A
+failing table does not stop the others, and a per-table summary is printed
+at the end.
+
+With --out-url, the export is streamed to that URL as a chunked POST instead
+of a file, with --compress setting Content-Encoding. --out-header adds
+request headers such as Authorization. A 5xx response is retried with
+backoff, re-sending the same snapshot; a 4xx response fails the export.
+
+With --out-s3 s3://bucket/key, the export is streamed to S3 as a multipart
+upload using the standard AWS credential chain; --compress appends .gz or .zst
+to the key. S3 support is only compiled in with -tags s3.
+
+--compress gzip or zstd compresses the output and appends .gz or .zst to
+--out and to each --out-dir file. zstd support is only compiled in with
+-tags zstd. --gzip is a deprecated alias for --compress gzip.
+
+With --format jsonarray, a single table is written as one JSON array of row
+objects, and several tables as one object mapping each table name to its
+array: {"sessions":[...],"external_repos":[...]}. Rows are streamed, not
+held in memory.
+
+Binary values in JSON output are wrapped as {"$b64":"..."}, which import
+decodes back to a BLOB. By default, values in BLOB columns are always
+wrapped, and values in other columns are written as text unless they are not
+valid UTF-8. --blob base64 wraps every binary value, --blob hex writes
+{"$hex":"..."} instead, and --blob string writes them all as text, which
+corrupts data that is not UTF-8.
+
+--with-schema writes a {"table":...,"schema":[{"name":...,"type":...}]}
+object before each table's rows, listing the exported columns with their
+declared types from PRAGMA table_info, so consumers can set up typed parsers.
+import skips these objects.
+
+--indent pretty-prints JSON for human inspection. With jsonl it spreads each
+object over several lines, so the result is no longer valid JSONL and cannot
+be imported.
+
+With --format csv, each table is written as CSV with a header row of column
+names. NULL values are written as empty, unquoted fields and empty strings
+as "", so the two stay distinct. JSON formats write NULL as null and sql
+writes NULL.
+
+With --format sql, each table's CREATE TABLE statement and batched INSERT
+statements are written inside one transaction, with foreign keys disabled so
+the dump loads regardless of table order.
+
+Rows are ordered by rowid (the primary key for WITHOUT ROWID tables), so
+exports of unchanged data are byte-identical and diff cleanly. --order-by
+takes columns per table, e.g. "sessions:created_at,id;rowid", or "none" to
+keep SQLite's natural order.
+
+--redact table:column replaces that column's values with "[REDACTED]" and
+--drop-columns table:column leaves the column out, in every format. Both are
+repeatable, take comma-separated columns, and fail if a column does not
+exist.
+
+--skip-empty leaves out tables with no rows, naming them on stderr, and
+--only-empty prints the names of those tables instead of exporting anything.
+Both look at the whole table, not at the rows --where selects.
+
+--limit N and --offset M take at most N rows from each table after skipping
+the first M, in --order-by order. They apply per table, not to the export as
+a whole.
+
+--continue-on-error logs a failing table and carries on with the rest, then
+prints each table's status and exits non-zero if any failed. Rows the failed
+table wrote before the error stay in a shared output stream; jsonarray
+output is kept well-formed, and a single-stream sql dump is refused because
+it would end in a truncated INSERT.
+
+--where applies the same SQL predicate to every exported table. Tables that
+lack a column the predicate references are skipped with a warning.
+
+--since-column and --since-value export only the rows added or changed since
+a previous run, for a column that increases with every change such as an id
+or updated_at. The highest value exported is printed at the end as the
+--since-value for the next run, on stderr when the export goes to stdout.
+Tables without the column are skipped with a warning. With --concurrency the
+watermark comes from the initial snapshot, so a later run may repeat rows
+but never misses them.
+
+Tables that take longer than a second report processed/total rows on stderr
+while stdout is a terminal. --quiet turns this off.`,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			end := traceCommand(cmd)
+			defer func() { end(err) }()
+
+			startedAt := time.Now()
+			switch format {
+			case "jsonl", "jsonarray", "csv", "sql":
+			default:
+				return fmt.Errorf("unknown format %q (want jsonl, jsonarray, csv or sql)", format)
+			}
+			if format == "jsonarray" && (header || legacyTS || raw) {
+				return fmt.Errorf("jsonarray rows carry no envelope; --header, --legacy-ts and --raw do not apply")
+			}
+			if gzipOut {
+				if cmd.Flags().Changed("compress") && compress != compressGzip {
+					return fmt.Errorf("--gzip conflicts with --compress %s", compress)
+				}
+				compress = compressGzip
+			}
+			switch compress {
+			case compressNone, compressGzip:
+			case compressZstd:
+				if !zstdSupported {
+					return errZstdUnsupported
+				}
+			default:
+				return fmt.Errorf("unknown --compress %q (want gzip, zstd or none)", compress)
+			}
+			if err := keys.validate(); err != nil {
+				return err
+			}
+			if keys != defaultEnvelopeKeys && (format != "jsonl" || raw) {
+				return fmt.Errorf("--key-table, --key-row and --key-ts rename the jsonl envelope and need --format jsonl without --raw")
+			}
+			switch blob {
+			case blobAuto, blobBase64, blobHex, blobString:
+			default:
+				return fmt.Errorf("unknown --blob %q (want auto, base64, hex or string)", blob)
+			}
+			if blob != blobAuto && format != "jsonl" && format != "jsonarray" {
+				return fmt.Errorf("--blob only applies to jsonl and jsonarray output")
+			}
+			if indent {
+				switch format {
+				case "jsonl":
+					fmt.Fprintln(os.Stderr, "warning: --indent spreads objects over several lines; the output is not valid JSONL")
+				case "jsonarray":
+				default:
+					return fmt.Errorf("--indent only applies to jsonl and jsonarray output")
+				}
+			}
+			if withSchema && (format != "jsonl" || raw) {
+				return fmt.Errorf("--with-schema needs --format jsonl without --raw")
+			}
+			if header && legacyTS {
+				return fmt.Errorf("--header and --legacy-ts are mutually exclusive")
+			}
+			if raw && (header || legacyTS) {
+				return fmt.Errorf("--raw writes no envelope and cannot be combined with --header or --legacy-ts")
+			}
+			if outDir != "" && outPath != "" {
+				return fmt.Errorf("--out and --out-dir are mutually exclusive")
+			}
+			if outURL != "" && (outPath != "" || outDir != "") {
+				return fmt.Errorf("--out-url cannot be combined with --out or --out-dir")
+			}
+			if outS3 != "" && (outPath != "" || outDir != "" || outURL != "") {
+				return fmt.Errorf("--out-s3 cannot be combined with --out, --out-dir or --out-url")
+			}
+			headers, err := parseHeaders(outHeaders)
+			if err != nil {
+				return err
+			}
+			if bufSize <= 0 {
+				return fmt.Errorf("--buffer must be positive")
+			}
+			if limit < 0 || offset < 0 {
+				return fmt.Errorf("--limit and --offset must not be negative")
+			}
+			if continueOnError && format == "sql" && outDir == "" {
+				return fmt.Errorf("--continue-on-error needs --out-dir with --format sql; a failed table would leave a truncated INSERT in the dump")
+			}
+			if skipEmpty && onlyEmpty {
+				return fmt.Errorf("--skip-empty and --only-empty are mutually exclusive")
+			}
+			if sinceValue != "" && sinceColumn == "" {
+				return fmt.Errorf("--since-value needs --since-column")
+			}
+			if sinceColumn != "" && (limit > 0 || offset > 0) {
+				// A partial page would move the watermark past rows it never
+				// exported.
+				return fmt.Errorf("--since-column cannot be combined with --limit or --offset")
+			}
+			if concurrency < 1 {
+				return fmt.Errorf("--concurrency must be at least 1")
+			}
+			if concurrency > 1 && outDir == "" {
+				return fmt.Errorf("--concurrency needs --out-dir")
+			}
+
+			database, err := openReadOnly(dbPath(), immutable)
 			if err != nil {
 				return err
 			}
 			defer database.Close()
 
+			columns, err := parseColumnSpec(columnsSpec)
+			if err != nil {
+				return err
+			}
+			orderBy, order, err := parseOrderSpec(orderSpec)
+			if err != nil {
+				return err
+			}
+			redactCols, err := parseColumnSpec(strings.Join(redact, ";"))
+			if err != nil {
+				return fmt.Errorf("--redact: %w", err)
+			}
+			dropCols, err := parseColumnSpec(strings.Join(dropColumns, ";"))
+			if err != nil {
+				return fmt.Errorf("--drop-columns: %w", err)
+			}
+			query := exportQuery{
+				columns: columns,
+				where:   sincePredicate(strings.TrimSpace(where), sinceColumn, sinceValue),
+				orderBy: orderBy,
+				order:   order,
+				redact:  redactCols,
+				drop:    dropCols,
+				limit:   limit,
+				offset:  offset,
+			}
+
 			tables := parseTableList(tablesCSV)
-			if len(tables) == 0 {
-				tables = []string{"sessions", "external_repos", "env_backups", "repo_dependencies"}
+			requested := len(tables) > 0
+			exclude := parseTableList(excludeCSV)
+			if requested && len(exclude) > 0 {
+				return fmt.Errorf("--tables and --exclude are mutually exclusive")
 			}
 
-			out, cleanup, err := openOutput(outPath)
+			// Run the whole export in one read-only transaction so every table
+			// comes from the same snapshot and --where cannot modify data.
+			tx, err := database.BeginTx(cmd.Context(), &sql.TxOptions{ReadOnly: true})
 			if err != nil {
 				return err
 			}
-			defer cleanup()
+			defer tx.Rollback()
+			if _, err := tx.Exec("PRAGMA query_only = ON"); err != nil {
+				return err
+			}
+			defer tx.Exec("PRAGMA query_only = OFF")
+			q := ctxQuerier{ctx: cmd.Context(), q: tx}
 
-			enc := json.NewEncoder(out)
-			for _, tbl := range tables {
-				if err := exportTable(database, tbl, enc); err != nil {
-					return fmt.Errorf("export %s: %w", tbl, err)
+			if requested {
+				if tables, err = validateTables(q, tables, ignoreMissing); err != nil {
+					return err
 				}
+			} else if tables, err = exportableTables(q, includeMigrations); err != nil {
+				return err
+			}
+			if _, err := validateTables(q, exclude, false); err != nil {
+				return fmt.Errorf("--exclude: %w", err)
+			}
+			tables = excludeTables(tables, exclude)
+			if outDir == "" && format == "csv" && len(tables) > 1 {
+				return fmt.Errorf("csv format writes one table per file; use --out-dir or select a single table with --tables")
+			}
+			if outDir == "" && raw && len(tables) > 1 {
+				return fmt.Errorf("--raw rows carry no table name; use --out-dir or select a single table with --tables")
 			}
 
-			if out != os.Stdout {
-				fmt.Printf("Exported %d tables to %s\n", len(tables), outPath)
+			if sinceColumn != "" {
+				if tables, err = tablesWithColumn(q, tables, sinceColumn); err != nil {
+					return err
+				}
 			}
-			return nil
+			if skipEmpty || onlyEmpty {
+				nonEmpty, empty, err := splitEmptyTables(q, tables)
+				if err != nil {
+					return err
+				}
+				if onlyEmpty {
+					for _, t := range empty {
+						fmt.Println(t)
+					}
+					fmt.Fprintf(os.Stderr, "%d of %d table(s) are empty\n", len(empty), len(tables))
+					return nil
+				}
+				if len(empty) > 0 {
+					fmt.Fprintf(os.Stderr, "Skipped %d empty table(s) (--skip-empty): %s\n", len(empty), strings.Join(empty, ", "))
+				}
+				tables = nonEmpty
+			}
+			var watermark any
+			if sinceColumn != "" {
+				if watermark, err = sinceWatermark(q, tables, sinceColumn, query.where); err != nil {
+					return err
+				}
+			}
+			// Status goes to stdout unless the export itself does.
+			statusOut := os.Stdout
+			if outDir == "" && outURL == "" && outS3 == "" && outPath == "" {
+				statusOut = os.Stderr
+			}
+			report := func(failed int) {
+				if sinceColumn == "" {
+					return
+				}
+				w := statusOut
+				switch {
+				case failed > 0:
+					// Rows of the failed tables were not exported, so moving
+					// the watermark past them would lose them.
+					fmt.Fprintf(w, "Watermark not advanced: %d table(s) failed\n", failed)
+					return
+				case watermark == nil && sinceValue == "":
+					fmt.Fprintf(w, "No rows have a %s value; no watermark\n", sinceColumn)
+					return
+				case watermark == nil:
+					fmt.Fprintf(w, "No rows after --since-value; watermark unchanged: %s\n", sinceValue)
+					return
+				}
+				fmt.Fprintf(w, "Next --since-value: %s\n", csvValue(watermark))
+			}
+
+			if err := checkColumnRefs(q, "--redact", redactCols); err != nil {
+				return err
+			}
+			if err := checkColumnRefs(q, "--drop-columns", dropCols); err != nil {
+				return err
+			}
+
+			opts := jsonlOptions{
+				ts:       startedAt.Unix(),
+				header:   header,
+				legacyTS: legacyTS,
+				raw:      raw,
+				keyed:    outDir == "" && len(tables) > 1,
+				indent:   indent,
+				schema:   withSchema,
+				blob:     blob,
+				keys:     keys,
+			}
+			f := newExportFormat(format, query, opts)
+			oo := outputOptions{compress: compress, bufSize: bufSize, flushEvery: flushEvery, progress: !quiet && stdoutIsTerminal()}
+			if continueOnError && outDir == "" {
+				oo.failures = map[string]error{}
+			}
+			// finish reports on a single-stream export, listing each
+			// table's outcome with --continue-on-error.
+			finish := func(counts map[string]int) error {
+				if continueOnError {
+					for _, t := range tables {
+						if err, ok := oo.failures[t]; ok {
+							fmt.Fprintf(statusOut, "  %s: failed: %v\n", t, err)
+						} else if n, ok := counts[t]; ok {
+							fmt.Fprintf(statusOut, "  %s: %d rows\n", t, n)
+						} else {
+							fmt.Fprintf(statusOut, "  %s: skipped\n", t)
+						}
+					}
+				}
+				report(len(oo.failures))
+				if len(oo.failures) > 0 {
+					return fmt.Errorf("%d of %d table(s) failed to export", len(oo.failures), len(tables))
+				}
+				return nil
+			}
+
+			if outDir != "" {
+				if err := os.MkdirAll(outDir, 0o755); err != nil {
+					return err
+				}
+				export := func(q querier, tbl string) tableExport {
+					return exportTableFile(q, outDir, oo, f, tbl, query)
+				}
+				if concurrency == 1 {
+					failed := 0
+					for _, tbl := range tables {
+						res := export(q, tbl)
+						switch {
+						case res.err != nil && !continueOnError:
+							return res.err
+						case res.err != nil:
+							failed++
+							fmt.Printf("  failed: %v\n", res.err)
+						case !res.skipped:
+							fmt.Printf("  %s: %d rows\n", res.path, res.rows)
+						}
+					}
+					report(failed)
+					if failed > 0 {
+						return fmt.Errorf("%d of %d table(s) failed to export", failed, len(tables))
+					}
+					return nil
+				}
+
+				// Progress lines from parallel tables would interleave.
+				oo.progress = false
+				failed := 0
+				for _, res := range exportConcurrently(cmd.Context(), database, concurrency, tables, export) {
+					switch {
+					case res.err != nil:
+						failed++
+						fmt.Printf("  failed: %v\n", res.err)
+					case !res.skipped:
+						fmt.Printf("  %s: %d rows\n", res.path, res.rows)
+					}
+				}
+				report(failed)
+				if failed > 0 {
+					return fmt.Errorf("%d of %d table(s) failed to export", failed, len(tables))
+				}
+				return nil
+			}
+
+			if outURL != "" {
+				counts, err := exportToURL(cmd.Context(), q, outURL, headers, oo, f, tables, query)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Exported %d tables to %s\n", len(counts), outURL)
+				return finish(counts)
+			}
+
+			if outS3 != "" {
+				if ext := compressExt(compress); !strings.HasSuffix(outS3, ext) {
+					outS3 += ext
+				}
+				contentType := exportContentTypes[f.ext]
+				if ct, ok := compressContentTypes[compress]; ok {
+					contentType = ct
+				}
+				sink, err := openS3Output(cmd.Context(), outS3, contentType)
+				if err != nil {
+					return err
+				}
+				counts, err := exportToSink(q, sink, oo, f, tables, query)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Exported %d tables to %s\n", len(counts), outS3)
+				return finish(counts)
+			}
+
+			if ext := compressExt(compress); outPath != "" && !strings.HasSuffix(outPath, ext) {
+				outPath += ext
+			}
+			counts, err := exportToFile(q, outPath, oo, f, tables, query)
+			if err != nil {
+				return err
+			}
+			if outPath != "" {
+				fmt.Printf("Exported %d tables to %s\n", len(counts), outPath)
+			}
+			return finish(counts)
 		},
 	}
 
-	cmd.Flags().StringVar(&tablesCSV, "tables", "", "Comma-separated table list")
+	cmd.Flags().StringVar(&tablesCSV, "tables", "", "Comma-separated table list (default: all tables)")
+	cmd.Flags().StringVar(&excludeCSV, "exclude", "", "Comma-separated tables to leave out of the default set")
+	cmd.Flags().BoolVar(&includeMigrations, "include-schema-migrations", false, "Include schema_migrations in the default set")
+	cmd.Flags().BoolVar(&ignoreMissing, "ignore-missing", false, "Skip --tables entries that do not exist instead of failing")
 	cmd.Flags().StringVar(&outPath, "out", "", "Output file path (default: stdout)")
+	cmd.Flags().StringVar(&outDir, "out-dir", "", "Write one file per table into this directory")
+	cmd.Flags().StringVar(&outURL, "out-url", "", "POST the export to this http(s) URL")
+	cmd.Flags().StringVar(&outS3, "out-s3", "", "Upload the export to this s3://bucket/key (needs a build with -tags s3)")
+	cmd.Flags().StringArrayVar(&outHeaders, "out-header", nil, "Request header for --out-url, e.g. \"Authorization: Bearer $TOKEN\" (repeatable)")
+	cmd.Flags().StringVar(&format, "format", "jsonl", "Output format: jsonl, jsonarray, csv or sql")
+	cmd.Flags().StringVar(&compress, "compress", compressNone, "Compress the output: gzip, zstd or none (appends .gz or .zst to --out)")
+	cmd.Flags().BoolVar(&gzipOut, "gzip", false, "Gzip the output (appends .gz to --out)")
+	cmd.Flags().MarkDeprecated("gzip", "use --compress gzip")
+	cmd.Flags().IntVar(&bufSize, "buffer", 64*1024, "Output buffer size in bytes")
+	cmd.Flags().IntVar(&flushEvery, "flush-every", 1000, "Flush buffered output every N rows (0 flushes only when the buffer fills)")
+	cmd.Flags().BoolVar(&immutable, "immutable", false, "Open the database with immutable=1 (only for files nothing is writing)")
+	cmd.Flags().BoolVar(&header, "header", false, "Write the export start time once in a header object instead of per row")
+	cmd.Flags().BoolVar(&legacyTS, "legacy-ts", false, "Stamp each row with the time it was written")
+	cmd.Flags().BoolVar(&raw, "raw", false, "Write bare row objects without the table/ts envelope")
+	cmd.Flags().StringVar(&keys.table, "key-table", defaultEnvelopeKeys.table, "Name of the envelope field holding the table name")
+	cmd.Flags().StringVar(&keys.row, "key-row", defaultEnvelopeKeys.row, "Name of the envelope field holding the row")
+	cmd.Flags().StringVar(&keys.ts, "key-ts", defaultEnvelopeKeys.ts, "Name of the envelope field holding the export time")
+	cmd.Flags().StringVar(&blob, "blob", blobAuto, "Encoding for binary values in JSON: auto, base64, hex or string")
+	cmd.Flags().BoolVar(&withSchema, "with-schema", false, "Write each table's column names and declared types before its rows")
+	cmd.Flags().BoolVar(&indent, "indent", false, "Pretty-print JSON objects for reading (jsonl output is then no longer line-delimited)")
+	cmd.Flags().IntVar(&limit, "limit", 0, "Export at most N rows per table (0 for all)")
+	cmd.Flags().IntVar(&offset, "offset", 0, "Skip the first N rows of each table")
+	cmd.Flags().StringVar(&where, "where", "", "SQL predicate applied to every exported table")
+	cmd.Flags().BoolVar(&continueOnError, "continue-on-error", false, "Keep exporting the other tables when one fails, and fail at the end")
+	cmd.Flags().BoolVar(&skipEmpty, "skip-empty", false, "Leave out tables that have no rows")
+	cmd.Flags().BoolVar(&onlyEmpty, "only-empty", false, "List the tables that have no rows instead of exporting")
+	cmd.Flags().StringVar(&sinceColumn, "since-column", "", "Only export rows whose value in this increasing column is above --since-value")
+	cmd.Flags().StringVar(&sinceValue, "since-value", "", "Watermark from the previous run for --since-column (default: export every row)")
+	cmd.Flags().BoolVar(&quiet, "quiet", false, "Do not report progress on stderr")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 1, "Export up to N tables in parallel (needs --out-dir)")
+	cmd.Flags().StringVar(&orderSpec, "order-by", "rowid", "Row order: rowid, none, or columns per table, e.g. sessions:created_at,id")
+	cmd.Flags().StringArrayVar(&redact, "redact", nil, "Replace a column's values with [REDACTED], e.g. env_backups:path (repeatable)")
+	cmd.Flags().StringArrayVar(&dropColumns, "drop-columns", nil, "Leave columns out of the export, e.g. env_backups:path,size (repeatable)")
+	cmd.Flags().StringVar(&columnsSpec, "columns", "", "Columns to export per table, e.g. sessions:id,created_at;external_repos:url")
 
 	return cmd
 }
@@ -232,7 +1212,7 @@ func newPathCmd() *cobra.Command {
 		Use:   "path",
 		Short: "Print database file path",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println(db.DefaultDBPath())
+			fmt.Println(dbPath())
 		},
 	}
 }
@@ -252,34 +1232,142 @@ func parseTableList(csv string) []string {
 	return out
 }
 
-func openOutput(path string) (*os.File, func(), error) {
+// openOutput opens a buffered writer on path (stdout when empty), optionally
+// gzip compressed. The returned cleanup flushes the buffer and any compressor
+// before closing the file and is safe to call more than once.
+func openOutput(path string, oo outputOptions) (*bufio.Writer, func() error, error) {
+	var f *os.File
 	if strings.TrimSpace(path) == "" {
-		return os.Stdout, func() {}, nil
+		f = os.Stdout
+	} else {
+		var err error
+		if f, err = os.Create(path); err != nil {
+			return nil, nil, err
+		}
 	}
-	f, err := os.Create(path)
+
+	bw, flush, err := wrapOutput(f, oo)
 	if err != nil {
+		if f != os.Stdout {
+			f.Close()
+		}
 		return nil, nil, err
 	}
-	return f, func() { f.Close() }, nil
+	closed := false
+	cleanup := func() error {
+		if closed {
+			return nil
+		}
+		closed = true
+		err := flush()
+		if f != os.Stdout {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}
+	return bw, cleanup, nil
 }
 
-func exportTable(database *sql.DB, table string, enc *json.Encoder) error {
-	var cnt int
-	if err := database.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type='table' AND name=?`, table).Scan(&cnt); err != nil || cnt == 0 {
-		return nil
+// wrapOutput buffers w, compressing it first as --compress says. flush
+// writes out the buffer and finishes the compressed stream but leaves w
+// open.
+func wrapOutput(w io.Writer, oo outputOptions) (*bufio.Writer, func() error, error) {
+	var zw io.WriteCloser
+	switch oo.compress {
+	case compressGzip:
+		zw = gzip.NewWriter(w)
+	case compressZstd:
+		var err error
+		if zw, err = newZstdWriter(w); err != nil {
+			return nil, nil, err
+		}
+	}
+	if zw != nil {
+		w = zw
+	}
+	bw := bufio.NewWriterSize(w, oo.bufSize)
+	flush := func() error {
+		err := bw.Flush()
+		if zw != nil {
+			if cerr := zw.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
 	}
+	return bw, flush, nil
+}
+
+func exportTable(database querier, table string, enc *json.Encoder, query exportQuery, opts jsonlOptions, onRow func() error) (int, error) {
+	var start func(cols []string) error
+	if opts.schema {
+		start = func(cols []string) error {
+			schema, err := exportSchema(database, table, cols)
+			if err != nil {
+				return fmt.Errorf("read schema: %w", err)
+			}
+			return enc.Encode(map[string]any{opts.keys.table: table, "schema": schema})
+		}
+	}
+	return scanJSONRows(database, table, query, opts.blob, start, func(row map[string]any) error {
+		if opts.raw {
+			if err := enc.Encode(row); err != nil {
+				return err
+			}
+			return onRow()
+		}
 
-	rows, err := database.Query("SELECT * FROM " + table)
+		obj := map[string]any{opts.keys.table: table, opts.keys.row: row}
+		switch {
+		case opts.legacyTS:
+			obj[opts.keys.ts] = time.Now().Unix()
+		case !opts.header:
+			obj[opts.keys.ts] = opts.ts
+		}
+		if err := enc.Encode(obj); err != nil {
+			return err
+		}
+		return onRow()
+	})
+}
+
+// scanJSONRows runs the export query for table and calls each with every
+// row as a map of JSON values, encoding []byte values as blob says. start,
+// if not nil, is called with the result columns once the query
+// has succeeded and before the first row, so output framing is only written
+// for tables that are actually exported. Missing tables are skipped.
+func scanJSONRows(database querier, table string, query exportQuery, blob string, start func(cols []string) error, each func(row map[string]any) error) (int, error) {
+	if ok, err := tableExists(database, table); err != nil || !ok {
+		return 0, err
+	}
+
+	stmt, err := query.selectSQL(database, table)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	rows, err := database.Query(stmt)
+	if err != nil {
+		return 0, err
 	}
 	defer rows.Close()
 
 	cols, err := rows.Columns()
 	if err != nil {
-		return err
+		return 0, err
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, err
+	}
+	if start != nil {
+		if err := start(cols); err != nil {
+			return 0, err
+		}
 	}
 
+	n := 0
 	for rows.Next() {
 		vals := make([]any, len(cols))
 		ptrs := make([]any, len(cols))
@@ -287,24 +1375,23 @@ func exportTable(database *sql.DB, table string, enc *json.Encoder) error {
 			ptrs[i] = &vals[i]
 		}
 		if err := rows.Scan(ptrs...); err != nil {
-			return err
+			return n, err
 		}
 
 		row := map[string]any{}
 		for i, c := range cols {
-			switch v := vals[i].(type) {
-			case []byte:
-				row[c] = string(v)
-			default:
-				row[c] = v
+			if b, ok := vals[i].([]byte); ok {
+				row[c] = blobValue(b, types[i].DatabaseTypeName(), blob)
+			} else {
+				row[c] = jsonValue(vals[i], types[i].DatabaseTypeName())
 			}
 		}
 
-		obj := map[string]any{"table": table, "row": row, "ts": time.Now().Unix()}
-		if err := enc.Encode(obj); err != nil {
-			return err
+		n++
+		if err := each(row); err != nil {
+			return n, err
 		}
 	}
 
-	return rows.Err()
+	return n, rows.Err()
 }
@@ -0,0 +1,426 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// schemaObject is one row of sqlite_master with DDL.
+type schemaObject struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	TblName string `json:"tbl_name"`
+	SQL     string `json:"sql"`
+}
+
+func newSchemaCmd() *cobra.Command {
+	var table string
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the live schema DDL",
+		Long: `Print the CREATE statements stored in sqlite_master, tables first, then
+indexes, views and triggers.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "sql" && format != "json" {
+				return fmt.Errorf("unsupported format %q (want sql or json)", format)
+			}
+
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			objs, err := schemaObjects(database)
+			if err != nil {
+				return err
+			}
+			if table != "" {
+				var match []schemaObject
+				for _, o := range objs {
+					if o.Name == table {
+						match = append(match, o)
+					}
+				}
+				if len(match) == 0 {
+					return fmt.Errorf("no schema object named %q", table)
+				}
+				objs = match
+			}
+
+			if format == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(objs)
+			}
+			for _, o := range objs {
+				fmt.Printf("%s;\n\n", o.SQL)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&table, "table", "", "Show only the object with this name")
+	cmd.Flags().StringVar(&format, "format", "sql", "Output format: sql or json")
+
+	cmd.AddCommand(newSchemaDiffCmd())
+	cmd.AddCommand(newSchemaDescribeCmd())
+
+	return cmd
+}
+
+func newSchemaDiffCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff",
+		Short: "Compare the live schema with the one migrations produce",
+		Long: `Apply the embedded migrations to a fresh in-memory database and compare
+the result with the live schema. Reports missing and extra objects, column
+differences and changed DDL, and exits non-zero when any drift is found.
+Whitespace differences in DDL are ignored.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			expected, err := openMemory(true)
+			if err != nil {
+				return fmt.Errorf("build expected schema: %w", err)
+			}
+			defer expected.Close()
+
+			drift, err := schemaDrift(expected, database)
+			if err != nil {
+				return err
+			}
+			if len(drift) == 0 {
+				fmt.Println("No schema drift.")
+				return nil
+			}
+			for _, d := range drift {
+				fmt.Println(d)
+			}
+			return fmt.Errorf("schema drift detected (%d difference(s))", len(drift))
+		},
+	}
+}
+
+// tableDescription is the column and index contract of one table, as
+// reported by schema describe.
+type tableDescription struct {
+	Name    string              `json:"name"`
+	Columns []columnDescription `json:"columns"`
+	Indexes []indexDescription  `json:"indexes"`
+}
+
+type columnDescription struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Nullable   bool    `json:"nullable"`
+	Default    *string `json:"default"`
+	PrimaryKey int     `json:"primary_key"`
+}
+
+type indexDescription struct {
+	Name    string   `json:"name"`
+	Unique  bool     `json:"unique"`
+	Origin  string   `json:"origin"`
+	Partial bool     `json:"partial"`
+	Columns []string `json:"columns"`
+}
+
+// indexExpression stands in for index keys that are expressions rather
+// than columns.
+const indexExpression = "<expression>"
+
+func newSchemaDescribeCmd() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "describe [table]",
+		Short: "Describe each table's columns and indexes",
+		Long: `Describe every table, or just the one named, from PRAGMA table_info and
+index_list/index_info: each column's name, declared type, nullability, default
+and primary key position (0 when not part of the key), and each index's
+columns. --format json gives a stable, machine-readable contract.
+
+Index origin is "c" for CREATE INDEX, "u" for UNIQUE constraints and "pk" for
+primary keys. Expression keys are shown as ` + indexExpression + `.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "text" && format != "json" {
+				return fmt.Errorf("unsupported format %q (want text or json)", format)
+			}
+
+			database, err := openReadOnly(dbPath(), false)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			tables, err := listTables(database)
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				if !containsString(tables, args[0]) {
+					return unknownTableError(database, args[0])
+				}
+				tables = args
+			}
+
+			descs := make([]tableDescription, 0, len(tables))
+			for _, tbl := range tables {
+				d, err := describeTable(database, tbl)
+				if err != nil {
+					return fmt.Errorf("describe %s: %w", tbl, err)
+				}
+				descs = append(descs, d)
+			}
+
+			if format == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(descs)
+			}
+			for i, d := range descs {
+				if i > 0 {
+					fmt.Println()
+				}
+				printTableDescription(d)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+
+	return cmd
+}
+
+func describeTable(q querier, table string) (tableDescription, error) {
+	d := tableDescription{Name: table, Columns: []columnDescription{}, Indexes: []indexDescription{}}
+
+	rows, err := q.Query(`SELECT name, type, "notnull", dflt_value, pk FROM pragma_table_info(?) ORDER BY cid`, table)
+	if err != nil {
+		return d, err
+	}
+	for rows.Next() {
+		var c columnDescription
+		var notNull bool
+		if err := rows.Scan(&c.Name, &c.Type, &notNull, &c.Default, &c.PrimaryKey); err != nil {
+			rows.Close()
+			return d, err
+		}
+		c.Nullable = !notNull
+		d.Columns = append(d.Columns, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return d, err
+	}
+
+	rows, err = q.Query(`SELECT name, "unique", origin, partial FROM pragma_index_list(?) ORDER BY name`, table)
+	if err != nil {
+		return d, err
+	}
+	for rows.Next() {
+		var ix indexDescription
+		if err := rows.Scan(&ix.Name, &ix.Unique, &ix.Origin, &ix.Partial); err != nil {
+			rows.Close()
+			return d, err
+		}
+		d.Indexes = append(d.Indexes, ix)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return d, err
+	}
+
+	for i := range d.Indexes {
+		cols, err := indexColumns(q, d.Indexes[i].Name)
+		if err != nil {
+			return d, err
+		}
+		d.Indexes[i].Columns = cols
+	}
+	return d, nil
+}
+
+func indexColumns(q querier, index string) ([]string, error) {
+	rows, err := q.Query(`SELECT name FROM pragma_index_info(?) ORDER BY seqno`, index)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols := []string{}
+	for rows.Next() {
+		var name sql.NullString
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if name.Valid {
+			cols = append(cols, name.String)
+		} else {
+			cols = append(cols, indexExpression)
+		}
+	}
+	return cols, rows.Err()
+}
+
+func printTableDescription(d tableDescription) {
+	fmt.Printf("%s\n", d.Name)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  COLUMN\tTYPE\tNULL\tDEFAULT\tPK")
+	for _, c := range d.Columns {
+		null := "no"
+		if c.Nullable {
+			null = "yes"
+		}
+		def := "-"
+		if c.Default != nil {
+			def = *c.Default
+		}
+		pk := "-"
+		if c.PrimaryKey > 0 {
+			pk = fmt.Sprint(c.PrimaryKey)
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", c.Name, c.Type, null, def, pk)
+	}
+	w.Flush()
+	for _, ix := range d.Indexes {
+		kind := "index"
+		if ix.Unique {
+			kind = "unique index"
+		}
+		partial := ""
+		if ix.Partial {
+			partial = " (partial)"
+		}
+		fmt.Printf("  %s %s on (%s)%s\n", kind, ix.Name, strings.Join(ix.Columns, ", "), partial)
+	}
+}
+
+// schemaDrift describes how live differs from expected, one line per
+// difference. The migration bookkeeping tables are skipped because arc-db
+// itself extends them (checksums, locking).
+func schemaDrift(expected, live querier) ([]string, error) {
+	want, err := schemaObjects(expected)
+	if err != nil {
+		return nil, err
+	}
+	have, err := schemaObjects(live)
+	if err != nil {
+		return nil, err
+	}
+	haveByName := map[string]schemaObject{}
+	for _, o := range have {
+		haveByName[o.Name] = o
+	}
+	wantByName := map[string]schemaObject{}
+	for _, o := range want {
+		wantByName[o.Name] = o
+	}
+
+	var out []string
+	for _, w := range want {
+		if strings.HasPrefix(w.TblName, "schema_migrations") {
+			continue
+		}
+		h, ok := haveByName[w.Name]
+		if !ok {
+			out = append(out, fmt.Sprintf("missing %s %s", w.Type, w.Name))
+			continue
+		}
+		if w.Type == "table" {
+			diffs, err := columnDrift(expected, live, w.Name)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, diffs...)
+		}
+		if normalizeDDL(w.SQL) != normalizeDDL(h.SQL) {
+			out = append(out, fmt.Sprintf("changed %s %s\n  expected: %s\n  live:     %s", w.Type, w.Name, normalizeDDL(w.SQL), normalizeDDL(h.SQL)))
+		}
+	}
+	for _, h := range have {
+		if strings.HasPrefix(h.TblName, "schema_migrations") {
+			continue
+		}
+		if _, ok := wantByName[h.Name]; !ok {
+			out = append(out, fmt.Sprintf("extra %s %s", h.Type, h.Name))
+		}
+	}
+	return out, nil
+}
+
+func columnDrift(expected, live querier, table string) ([]string, error) {
+	want, err := tableColumns(expected, table)
+	if err != nil {
+		return nil, err
+	}
+	have, err := tableColumns(live, table)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, c := range want {
+		if !containsString(have, c) {
+			out = append(out, fmt.Sprintf("missing column %s.%s", table, c))
+		}
+	}
+	for _, c := range have {
+		if !containsString(want, c) {
+			out = append(out, fmt.Sprintf("extra column %s.%s", table, c))
+		}
+	}
+	return out, nil
+}
+
+var (
+	ddlSpaceRe = regexp.MustCompile(`\s+`)
+	ddlPunctRe = regexp.MustCompile(`\s*([(),])\s*`)
+)
+
+// normalizeDDL collapses whitespace so formatting-only differences compare
+// equal.
+func normalizeDDL(ddl string) string {
+	ddl = ddlSpaceRe.ReplaceAllString(strings.TrimSpace(ddl), " ")
+	return ddlPunctRe.ReplaceAllString(ddl, "$1")
+}
+
+// schemaObjects returns every user object with DDL, ordered so that tables
+// come before the indexes, views and triggers that depend on them.
+func schemaObjects(database querier) ([]schemaObject, error) {
+	rows, err := database.Query(`SELECT type, name, tbl_name, sql FROM sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+		ORDER BY CASE type WHEN 'table' THEN 0 WHEN 'index' THEN 1 WHEN 'view' THEN 2 ELSE 3 END, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []schemaObject
+	for rows.Next() {
+		var o schemaObject
+		if err := rows.Scan(&o.Type, &o.Name, &o.TblName, &o.SQL); err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const shellHelp = `Enter SQL statements terminated by ";". Meta-commands:
+  .tables          List tables
+  .schema [name]   Show CREATE statements, optionally for one table
+  .help            Show this help
+  .quit            Exit (also .exit or Ctrl-D)`
+
+func newShellCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "shell",
+		Short: "Start an interactive SQL shell",
+		Long: `Start a simple SQL REPL against the database chosen by --db, ARC_DB_PATH or
+the default path. Statements may span several lines and run when a line ends
+with ";". Results are printed as aligned tables.
+
+` + shellHelp,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := dbPath()
+			database, err := openDB(path)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			interactive := false
+			if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+				interactive = true
+				fmt.Printf("arc-db shell on %s; .help for help\n", path)
+			}
+			return runShell(cmd.Context(), database, os.Stdin, interactive)
+		},
+	}
+}
+
+// runShell reads statements and meta-commands from in until EOF or .quit.
+// Errors from individual statements are printed and the shell carries on.
+// Prompts are only written when interactive.
+func runShell(ctx context.Context, database *sql.DB, in io.Reader, interactive bool) error {
+	prompt := func(cont bool) {
+		if !interactive {
+			return
+		}
+		if cont {
+			fmt.Print("   ...> ")
+		} else {
+			fmt.Print("arc-db> ")
+		}
+	}
+
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var buf strings.Builder
+	prompt(false)
+	for sc.Scan() {
+		line := sc.Text()
+		if buf.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), ".") {
+			quit, err := shellMeta(database, strings.Fields(line))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			if quit {
+				return nil
+			}
+			prompt(false)
+			continue
+		}
+
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+		stmt := strings.TrimSpace(buf.String())
+		if stmt == "" || isOnlyComments(stmt) {
+			buf.Reset()
+			prompt(false)
+			continue
+		}
+		if !strings.HasSuffix(stmt, ";") || insideTrigger(stmt) {
+			prompt(true)
+			continue
+		}
+		buf.Reset()
+
+		if err := shellExec(ctx, database, stmt); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		prompt(false)
+	}
+	if interactive {
+		fmt.Println()
+	}
+	return sc.Err()
+}
+
+func shellExec(ctx context.Context, database *sql.DB, stmt string) error {
+	if !isReadOnlyStatement(stmt) {
+		res, err := database.ExecContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+		// RowsAffected still holds the last DML count after DDL.
+		switch leadingKeyword(stmt) {
+		case "INSERT", "UPDATE", "DELETE", "REPLACE":
+			n, _ := res.RowsAffected()
+			fmt.Printf("%d row(s) affected\n", n)
+		}
+		return nil
+	}
+
+	rows, err := database.QueryContext(ctx, stmt)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	return printRowsTable(rows)
+}
+
+// shellMeta runs a dot command and reports whether the shell should exit.
+func shellMeta(database *sql.DB, fields []string) (bool, error) {
+	switch fields[0] {
+	case ".quit", ".exit":
+		return true, nil
+	case ".help":
+		fmt.Println(shellHelp)
+	case ".tables":
+		tables, err := listTables(database)
+		if err != nil {
+			return false, err
+		}
+		for _, t := range tables {
+			fmt.Println(t)
+		}
+	case ".schema":
+		objs, err := schemaObjects(database)
+		if err != nil {
+			return false, err
+		}
+		for _, o := range objs {
+			if len(fields) > 1 && o.TblName != fields[1] {
+				continue
+			}
+			fmt.Printf("%s;\n", o.SQL)
+		}
+	default:
+		return false, fmt.Errorf("unknown command %s; enter .help for help", fields[0])
+	}
+	return false, nil
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// dbSize is the footprint reported by the size command.
+type dbSize struct {
+	FileBytes  int64 `json:"file_bytes"`
+	WALBytes   int64 `json:"wal_bytes"`
+	SHMBytes   int64 `json:"shm_bytes"`
+	TotalBytes int64 `json:"total_bytes"`
+	PageSize   int64 `json:"page_size"`
+	PageCount  int64 `json:"page_count"`
+	FreePages  int64 `json:"free_pages"`
+	FreeBytes  int64 `json:"free_bytes"`
+	InUseBytes int64 `json:"in_use_bytes"`
+}
+
+func newSizeCmd() *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "size",
+		Short: "Report database, WAL and free space sizes",
+		Long: `Report the size of the database file and its -wal and -shm sidecars, the
+bytes held by free pages, and the bytes in use. A large free share means
+vacuum would shrink the file.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := dbPath()
+
+			// Stat the sidecars before opening, which may create them.
+			var sz dbSize
+			st, err := os.Stat(path)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return fmt.Errorf("database %s does not exist", path)
+				}
+				return err
+			}
+			sz.FileBytes = st.Size()
+			if st, err := os.Stat(path + "-wal"); err == nil {
+				sz.WALBytes = st.Size()
+			}
+			if st, err := os.Stat(path + "-shm"); err == nil {
+				sz.SHMBytes = st.Size()
+			}
+			sz.TotalBytes = sz.FileBytes + sz.WALBytes + sz.SHMBytes
+
+			database, err := openReadOnly(path, false)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			for _, p := range []struct {
+				pragma string
+				dst    *int64
+			}{
+				{"page_size", &sz.PageSize},
+				{"page_count", &sz.PageCount},
+				{"freelist_count", &sz.FreePages},
+			} {
+				if err := database.QueryRow("PRAGMA " + p.pragma).Scan(p.dst); err != nil {
+					return fmt.Errorf("%s: %w", p.pragma, err)
+				}
+			}
+			sz.FreeBytes = sz.FreePages * sz.PageSize
+			sz.InUseBytes = (sz.PageCount - sz.FreePages) * sz.PageSize
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(sz)
+			}
+			fmt.Printf("Database: %d bytes\n", sz.FileBytes)
+			fmt.Printf("WAL:      %d bytes\n", sz.WALBytes)
+			fmt.Printf("SHM:      %d bytes\n", sz.SHMBytes)
+			fmt.Printf("Total:    %d bytes\n", sz.TotalBytes)
+			fmt.Println()
+			fmt.Printf("Pages:    %d x %d bytes\n", sz.PageCount, sz.PageSize)
+			fmt.Printf("Free:     %d bytes (%d pages)\n", sz.FreeBytes, sz.FreePages)
+			fmt.Printf("In use:   %d bytes\n", sz.InUseBytes)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print sizes as a JSON object")
+
+	return cmd
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yourorg/arc-sdk/db/migrations"
+)
+
+// snapshotLabelRe limits labels to names that are safe as file names.
+var snapshotLabelRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// snapshotMeta is the <label>.json sidecar written next to each snapshot.
+type snapshotMeta struct {
+	Label         string    `json:"label"`
+	CreatedAt     time.Time `json:"created_at"`
+	Source        string    `json:"source"`
+	SQLiteVersion string    `json:"sqlite_version"`
+	SchemaVersion int       `json:"schema_version"`
+	Bytes         int64     `json:"bytes"`
+}
+
+func newSnapshotCmd() *cobra.Command {
+	var dir string
+
+	sc := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Save and restore labelled copies of the database",
+		Long: `Keep named copies of the database for reproducible testing. Snapshots are
+stored in --dir (default: a snapshots directory next to the database) as
+<label>.db with a <label>.json sidecar recording when the snapshot was taken,
+the SQLite version and the schema version.`,
+		RunE: func(cmd *cobra.Command, args []string) error { return cmd.Help() },
+	}
+	sc.PersistentFlags().StringVar(&dir, "dir", "", "Snapshot directory (default: snapshots/ next to the database)")
+	snapshotDir := func() string {
+		if dir != "" {
+			return dir
+		}
+		return filepath.Join(filepath.Dir(dbPath()), "snapshots")
+	}
+
+	var force bool
+	saveCmd := &cobra.Command{
+		Use:   "save <label>",
+		Short: "Save a snapshot of the database under a label",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			label := args[0]
+			if err := checkSnapshotLabel(label); err != nil {
+				return err
+			}
+			dir := snapshotDir()
+			if err := os.MkdirAll(dir, 0o700); err != nil {
+				return err
+			}
+			dbFile, metaFile := snapshotFiles(dir, label)
+			if _, err := os.Stat(dbFile); err == nil {
+				if !force {
+					return fmt.Errorf("snapshot %q already exists (use --force to overwrite)", label)
+				}
+				if err := os.Remove(dbFile); err != nil {
+					return err
+				}
+			}
+
+			path := dbPath()
+			database, err := openDB(path)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			meta := snapshotMeta{Label: label, CreatedAt: time.Now().UTC(), Source: path}
+			if err := database.QueryRow("SELECT sqlite_version()").Scan(&meta.SQLiteVersion); err != nil {
+				return err
+			}
+			if meta.SchemaVersion, err = schemaVersion(database); err != nil {
+				return err
+			}
+			if _, err := execLogged(cmd.Context(), database, "VACUUM INTO ?", dbFile); err != nil {
+				return err
+			}
+			st, err := os.Stat(dbFile)
+			if err != nil {
+				return err
+			}
+			meta.Bytes = st.Size()
+			if err := writeSnapshotMeta(metaFile, meta); err != nil {
+				os.Remove(dbFile)
+				return err
+			}
+			fmt.Printf("Saved snapshot %s (schema version %03d, %d bytes)\n", label, meta.SchemaVersion, meta.Bytes)
+			return nil
+		},
+	}
+	saveCmd.Flags().BoolVar(&force, "force", false, "Overwrite an existing snapshot with the same label")
+	sc.AddCommand(saveCmd)
+
+	var yes bool
+	restoreCmd := &cobra.Command{
+		Use:   "restore <label>",
+		Short: "Replace the live database with a snapshot",
+		Long: `Replace the live database with a snapshot, as restore --from would. Refuses
+snapshots whose schema version is newer than the migrations this binary
+knows about.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			label := args[0]
+			if err := checkSnapshotLabel(label); err != nil {
+				return err
+			}
+			dbFile, metaFile := snapshotFiles(snapshotDir(), label)
+			meta, err := readSnapshotMeta(metaFile)
+			if err != nil {
+				return fmt.Errorf("snapshot %q: %w", label, err)
+			}
+
+			avail, err := migrations.Embedded()
+			if err != nil {
+				return err
+			}
+			known := 0
+			for _, m := range avail {
+				known = max(known, m.Version)
+			}
+			if meta.SchemaVersion > known {
+				return fmt.Errorf("snapshot %q is at schema version %03d, newer than the latest migration this binary knows (%03d)", label, meta.SchemaVersion, known)
+			}
+
+			if err := validateSQLiteFile(dbFile); err != nil {
+				return fmt.Errorf("invalid snapshot %s: %w", dbFile, err)
+			}
+			path := dbPath()
+			if !yes {
+				fmt.Printf("This will replace %s with snapshot %s.\n", path, label)
+				return fmt.Errorf("refusing to restore without --yes")
+			}
+			if err := replaceDatabase(path, dbFile); err != nil {
+				return err
+			}
+			fmt.Printf("Restored %s from snapshot %s (taken %s)\n", path, label, meta.CreatedAt.Format(time.RFC3339))
+			return nil
+		},
+	}
+	restoreCmd.Flags().BoolVar(&yes, "yes", false, "Confirm replacing the live database")
+	sc.AddCommand(restoreCmd)
+
+	sc.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List saved snapshots",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := snapshotDir()
+			files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+			if err != nil {
+				return err
+			}
+			var metas []snapshotMeta
+			for _, f := range files {
+				meta, err := readSnapshotMeta(f)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "warning: %s: %v\n", f, err)
+					continue
+				}
+				metas = append(metas, meta)
+			}
+			if len(metas) == 0 {
+				fmt.Printf("No snapshots in %s\n", dir)
+				return nil
+			}
+			sort.Slice(metas, func(i, j int) bool { return metas[i].CreatedAt.Before(metas[j].CreatedAt) })
+			fmt.Printf("%-24s %-20s %-7s %-8s %s\n", "LABEL", "CREATED", "SCHEMA", "SQLITE", "BYTES")
+			for _, m := range metas {
+				fmt.Printf("%-24s %-20s %-7s %-8s %d\n", m.Label, m.CreatedAt.Format("2006-01-02 15:04:05"),
+					fmt.Sprintf("%03d", m.SchemaVersion), m.SQLiteVersion, m.Bytes)
+			}
+			return nil
+		},
+	})
+
+	sc.AddCommand(&cobra.Command{
+		Use:   "rm <label>",
+		Short: "Delete a snapshot",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			label := args[0]
+			if err := checkSnapshotLabel(label); err != nil {
+				return err
+			}
+			dbFile, metaFile := snapshotFiles(snapshotDir(), label)
+			if _, err := os.Stat(metaFile); err != nil {
+				return fmt.Errorf("no snapshot %q", label)
+			}
+			for _, f := range []string{dbFile, metaFile} {
+				if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			fmt.Printf("Removed snapshot %s\n", label)
+			return nil
+		},
+	})
+
+	return sc
+}
+
+func checkSnapshotLabel(label string) error {
+	if !snapshotLabelRe.MatchString(label) {
+		return fmt.Errorf("invalid label %q (use letters, digits, '.', '_' and '-')", label)
+	}
+	return nil
+}
+
+// snapshotFiles returns the database and sidecar paths for label in dir.
+func snapshotFiles(dir, label string) (string, string) {
+	base := filepath.Join(dir, label)
+	return base + ".db", base + ".json"
+}
+
+func writeSnapshotMeta(path string, meta snapshotMeta) error {
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o600)
+}
+
+func readSnapshotMeta(path string) (snapshotMeta, error) {
+	var meta snapshotMeta
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return meta, fmt.Errorf("no such snapshot")
+		}
+		return meta, err
+	}
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return meta, fmt.Errorf("bad metadata: %w", err)
+	}
+	if meta.Label == "" {
+		meta.Label = strings.TrimSuffix(filepath.Base(path), ".json")
+	}
+	return meta, nil
+}
+
+// schemaVersion returns the highest applied migration version, or 0 when no
+// migration has been recorded. Unlike migrations.Applied it never creates
+// the schema_migrations table.
+func schemaVersion(q querier) (int, error) {
+	if ok, err := checkTable(q, migrationsTable); err != nil || !ok {
+		return 0, err
+	}
+	var v int
+	err := q.QueryRow("SELECT coalesce(max(version), 0) FROM " + migrationsTable).Scan(&v)
+	return v, err
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// statsTable holds the row count history recorded by stats snapshot.
+const statsTable = "table_stats"
+
+func newStatsCmd() *cobra.Command {
+	sc := &cobra.Command{
+		Use:   "stats",
+		Short: "Record and show table row counts over time",
+		RunE:  func(cmd *cobra.Command, args []string) error { return cmd.Help() },
+	}
+
+	snapshotCmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Record the current row count of every table",
+		Long: `Append the row count of every table to the table_stats table, creating it
+on first use. Run it from cron to build up a growth history for stats show.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			n, err := snapshotStats(database, time.Now())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Recorded row counts for %d tables\n", n)
+			return nil
+		},
+	}
+	sc.AddCommand(snapshotCmd)
+
+	var table string
+	var since string
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print recorded row counts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var cutoff time.Time
+			if since != "" {
+				age, err := parseAge(since)
+				if err != nil {
+					return err
+				}
+				cutoff = time.Now().Add(-age)
+			}
+
+			database, err := openReadOnly(dbPath(), false)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			if ok, err := checkTable(database, statsTable); err != nil {
+				return err
+			} else if !ok {
+				return fmt.Errorf("no stats recorded yet; run arc-db stats snapshot")
+			}
+
+			query := `SELECT "table", count, ts FROM table_stats WHERE ts >= ?`
+			queryArgs := []any{cutoff.Unix()}
+			if table != "" {
+				query += ` AND "table" = ?`
+				queryArgs = append(queryArgs, table)
+			}
+			rows, err := database.Query(query+` ORDER BY "table", ts`, queryArgs...)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "TIME\tTABLE\tROWS\tCHANGE")
+			prev := map[string]int64{}
+			for rows.Next() {
+				var tbl string
+				var count, ts int64
+				if err := rows.Scan(&tbl, &count, &ts); err != nil {
+					return err
+				}
+				change := "-"
+				if p, ok := prev[tbl]; ok {
+					change = fmt.Sprintf("%+d", count-p)
+				}
+				prev[tbl] = count
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", time.Unix(ts, 0).Format(time.RFC3339), tbl, count, change)
+			}
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			if len(prev) == 0 {
+				fmt.Println("No matching stats recorded.")
+				return nil
+			}
+			return w.Flush()
+		},
+	}
+	showCmd.Flags().StringVar(&table, "table", "", "Only show this table")
+	showCmd.Flags().StringVar(&since, "since", "", "Only show snapshots newer than this age, e.g. 30d")
+	sc.AddCommand(showCmd)
+
+	return sc
+}
+
+// snapshotStats records the row count of every table except table_stats
+// itself, all stamped with now, in one transaction.
+func snapshotStats(database *sql.DB, now time.Time) (int, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS table_stats (
+		"table" TEXT NOT NULL,
+		count INTEGER NOT NULL,
+		ts INTEGER NOT NULL
+	)`); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_table_stats_table_ts ON table_stats("table", ts)`); err != nil {
+		return 0, err
+	}
+
+	tables, err := listTables(tx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, tbl := range tables {
+		if tbl == statsTable {
+			continue
+		}
+		cnt, err := countRows(tx, quoteIdent(tbl))
+		if err != nil {
+			return 0, fmt.Errorf("count %s: %w", tbl, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO table_stats("table", count, ts) VALUES(?, ?, ?)`, tbl, cnt, now.Unix()); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	return n, tx.Commit()
+}
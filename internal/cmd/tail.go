@@ -0,0 +1,143 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newTailCmd() *cobra.Command {
+	var interval time.Duration
+	var format string
+	var fromStart bool
+
+	cmd := &cobra.Command{
+		Use:   "tail <table>",
+		Short: "Print rows as they are inserted into a table",
+		Long: `Poll a table for rows with a rowid above the last one seen and print them as
+they appear, like tail -f. Starts after the current last row unless
+--from-start is given. Stop with Ctrl-C.
+
+Rows are printed tab-separated under a header line, or with --format json as
+one JSON object per line.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			table := args[0]
+			if format != "text" && format != "json" {
+				return fmt.Errorf("unsupported format %q (want text or json)", format)
+			}
+			if interval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+
+			database, err := openReadOnly(dbPath(), false)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			if ok, err := checkTable(database, table); err != nil {
+				return err
+			} else if !ok {
+				return unknownTableError(database, table)
+			}
+
+			var last int64
+			if !fromStart {
+				if err := database.QueryRow("SELECT coalesce(max(rowid), 0) FROM " + quoteIdent(table)).Scan(&last); err != nil {
+					if strings.Contains(err.Error(), "no such column: rowid") {
+						return fmt.Errorf("%s is a WITHOUT ROWID table and cannot be tailed", table)
+					}
+					return err
+				}
+			}
+
+			ctx := cmd.Context()
+			t := &tailer{table: table, json: format == "json", last: last}
+			for {
+				if err := t.poll(ctxQuerier{ctx: ctx, q: database}); err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					return err
+				}
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(interval):
+				}
+			}
+		},
+	}
+
+	cmd.Flags().DurationVar(&interval, "interval", time.Second, "How often to poll for new rows")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+	cmd.Flags().BoolVar(&fromStart, "from-start", false, "Print existing rows first instead of only new ones")
+
+	return cmd
+}
+
+// tailer remembers the last rowid printed for a table.
+type tailer struct {
+	table  string
+	json   bool
+	last   int64
+	header bool
+}
+
+// poll prints the rows added since the last poll, in rowid order.
+func (t *tailer) poll(q querier) error {
+	rows, err := q.Query("SELECT rowid, * FROM "+quoteIdent(t.table)+" WHERE rowid > ? ORDER BY rowid", t.last)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+
+	for rows.Next() {
+		vals, err := scanRow(rows, len(cols))
+		if err != nil {
+			return err
+		}
+		rowid, _ := vals[0].(int64)
+		vals, names := vals[1:], cols[1:]
+
+		if t.json {
+			row := map[string]any{}
+			for i, c := range names {
+				row[c] = jsonValue(vals[i], types[i+1].DatabaseTypeName())
+			}
+			if err := enc.Encode(row); err != nil {
+				return err
+			}
+		} else {
+			if !t.header {
+				fmt.Println(strings.ToUpper(strings.Join(names, "\t")))
+				t.header = true
+			}
+			fields := make([]string, len(vals))
+			for i, v := range vals {
+				fields[i] = displayValue(v)
+			}
+			fmt.Println(strings.Join(fields, "\t"))
+		}
+		t.last = rowid
+	}
+	return rows.Err()
+}
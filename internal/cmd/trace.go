@@ -0,0 +1,110 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// traceFlag holds the persistent --trace flag.
+var traceFlag string
+
+// tracer is told when a span starts and ends. Long-running commands open a
+// span for the whole run, and the statements they execute open child spans
+// on the context it returns. db.Open cannot wrap the driver, so only
+// statements run through execLogged and ctxQuerier are traced. Bridging to
+// OpenTelemetry means implementing this interface; arc-db does not depend
+// on OTel itself.
+type tracer interface {
+	Start(ctx context.Context, kind, name string) context.Context
+	End(ctx context.Context, err error)
+}
+
+// activeTracer is nil, tracing nothing, unless --trace installs a
+// jsonTracer.
+var activeTracer tracer
+
+// startSpan opens a span under any span already on ctx. Call the returned
+// func with the operation's error to close it.
+func startSpan(ctx context.Context, kind, name string) (context.Context, func(error)) {
+	if activeTracer == nil {
+		return ctx, func(error) {}
+	}
+	ctx = activeTracer.Start(ctx, kind, name)
+	return ctx, func(err error) { activeTracer.End(ctx, err) }
+}
+
+// traceCommand opens a span named after cmd and makes it the parent of the
+// statements the command runs by installing it on cmd's context.
+func traceCommand(cmd *cobra.Command) func(error) {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, end := startSpan(ctx, "command", cmd.CommandPath())
+	cmd.SetContext(ctx)
+	return end
+}
+
+// traceSpan is one line of --trace output. Trace is random per run, so
+// runs appended to the same file can be told apart.
+type traceSpan struct {
+	Trace      string    `json:"trace"`
+	ID         int64     `json:"id"`
+	Parent     int64     `json:"parent,omitempty"`
+	Kind       string    `json:"kind"`
+	Name       string    `json:"name"`
+	Start      time.Time `json:"start"`
+	DurationMS float64   `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type spanKey struct{}
+
+// jsonTracer writes each finished span as a JSON line. Children finish, and
+// so are written, before their parents.
+type jsonTracer struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	trace  string
+	nextID atomic.Int64
+}
+
+func newJSONTracer(w io.Writer) *jsonTracer {
+	var id [8]byte
+	rand.Read(id[:])
+	return &jsonTracer{enc: json.NewEncoder(w), trace: hex.EncodeToString(id[:])}
+}
+
+func (t *jsonTracer) Start(ctx context.Context, kind, name string) context.Context {
+	s := &traceSpan{Trace: t.trace, ID: t.nextID.Add(1), Kind: kind, Name: name, Start: time.Now()}
+	if parent, ok := ctx.Value(spanKey{}).(*traceSpan); ok {
+		s.Parent = parent.ID
+	}
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+func (t *jsonTracer) End(ctx context.Context, err error) {
+	s, ok := ctx.Value(spanKey{}).(*traceSpan)
+	if !ok {
+		return
+	}
+	out := *s
+	out.DurationMS = float64(time.Since(s.Start).Microseconds()) / 1000
+	if err != nil {
+		out.Error = err.Error()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.enc.Encode(out)
+}
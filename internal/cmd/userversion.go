@@ -0,0 +1,67 @@
+// Copyright (c) 2025 Arc Engineering
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+func newUserVersionCmd() *cobra.Command {
+	uc := &cobra.Command{
+		Use:   "user-version",
+		Short: "Print or set PRAGMA user_version",
+		Long: `Print SQLite's user_version, an application-defined integer stored in the
+database header that is independent of schema_migrations.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := openReadOnly(dbPath(), false)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			var v int64
+			if err := database.QueryRowContext(cmd.Context(), "PRAGMA user_version").Scan(&v); err != nil {
+				return err
+			}
+			fmt.Println(v)
+			return nil
+		},
+	}
+
+	setCmd := &cobra.Command{
+		Use:   "set <n>",
+		Short: "Set PRAGMA user_version",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n, err := strconv.ParseInt(args[0], 10, 32)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid user version %q: want an integer from 0 to 2147483647", args[0])
+			}
+
+			database, err := openDB(dbPath())
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			var old int64
+			if err := database.QueryRowContext(cmd.Context(), "PRAGMA user_version").Scan(&old); err != nil {
+				return err
+			}
+			// PRAGMA arguments cannot be bound; n is a validated integer.
+			if _, err := execLogged(cmd.Context(), database, fmt.Sprintf("PRAGMA user_version = %d", n)); err != nil {
+				return err
+			}
+			fmt.Printf("Set user_version from %d to %d\n", old, n)
+			return nil
+		},
+	}
+	uc.AddCommand(setCmd)
+
+	return uc
+}
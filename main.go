@@ -4,14 +4,23 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/yourorg/arc-db/internal/cmd"
 )
 
 func main() {
+	// Ctrl-C cancels the command's context so long-running statements are
+	// interrupted and open transactions roll back.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
 	root := cmd.NewRootCmd()
-	if err := root.Execute(); err != nil {
-		os.Exit(1)
+	err := root.ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		os.Exit(cmd.ExitCode(err))
 	}
 }